@@ -0,0 +1,46 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Appender is implemented by drivers which can append to a file without
+// rewriting it, see Capabilities.Append. An appender creates a missing
+// file, the appended content is visible at the latest after Close.
+type Appender interface {
+	NewAppender(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error)
+}
+
+func (f *fileStore) NewAppender(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := d.(Appender); ok && d.Capabilities().Append {
+		return a.NewAppender(ctx, p, opts...)
+	}
+	return emulateAppender(ctx, d, p, opts...)
+}
+
+// emulateAppender copies the existing content of a file to an atomic
+// writer, which replaces the file on Close. Concurrent appends may be lost.
+func emulateAppender(ctx context.Context, d StorageDriver, p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	r, err := d.NewReader(ctx, p)
+	if errors.Is(err, ErrNotExist) {
+		return d.NewAtomicWriter(ctx, p, opts...)
+	} else if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	w, err := d.NewAtomicWriter(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w, nil
+}
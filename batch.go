@@ -0,0 +1,130 @@
+package filab
+
+import (
+	"context"
+	"errors"
+)
+
+// ExistResult is a result of ExistMany for one path.
+type ExistResult struct {
+	Path  Path
+	Exist bool
+	Err   error
+}
+
+// StatResult is a result of StatMany for one path.
+type StatResult struct {
+	Path Path
+	Info FileInfo
+	Err  error
+}
+
+// DeleteResult is a result of DeleteMany for one path.
+type DeleteResult struct {
+	Path Path
+	Err  error
+}
+
+// BatchOptions configure operations on many files.
+type BatchOptions struct {
+	// Concurrency is a maximal number of parallel requests.
+	Concurrency int
+}
+
+type BatchOption interface {
+	applyBatch(*BatchOptions)
+}
+
+// NewBatchOptions applies opts on top of the default options.
+func NewBatchOptions(opts ...BatchOption) BatchOptions {
+	o := BatchOptions{Concurrency: DefaultConcurrency}
+	for _, v := range opts {
+		v.applyBatch(&o)
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	return o
+}
+
+type withConcurrency int
+
+func (n withConcurrency) applyBatch(o *BatchOptions) {
+	o.Concurrency = int(n)
+}
+
+// WithConcurrency limits a number of parallel requests of a batch.
+func WithConcurrency(n int) BatchOption {
+	return withConcurrency(n)
+}
+
+// BatchExist checks paths in parallel. Results are in the order of paths,
+// paths not checked before ctx is done have ctx's error.
+func BatchExist(ctx context.Context, s FileStoreBase, paths []Path, opts ...BatchOption) []ExistResult {
+	ret := make([]ExistResult, len(paths))
+	forEach(ctx, len(paths), NewBatchOptions(opts...).Concurrency, func(i int) {
+		ok, err := s.Exist(ctx, paths[i])
+		ret[i] = ExistResult{Path: paths[i], Exist: ok, Err: err}
+	})
+	for i, v := range ret {
+		if v.Path == nil {
+			ret[i] = ExistResult{Path: paths[i], Err: ctx.Err()}
+		}
+	}
+	return ret
+}
+
+// BatchStat is like BatchExist for Stat.
+func BatchStat(ctx context.Context, s FileStoreBase, paths []Path, opts ...BatchOption) []StatResult {
+	ret := make([]StatResult, len(paths))
+	forEach(ctx, len(paths), NewBatchOptions(opts...).Concurrency, func(i int) {
+		info, err := s.Stat(ctx, paths[i])
+		ret[i] = StatResult{Path: paths[i], Info: info, Err: err}
+	})
+	for i, v := range ret {
+		if v.Path == nil {
+			ret[i] = StatResult{Path: paths[i], Err: ctx.Err()}
+		}
+	}
+	return ret
+}
+
+// BatchDelete is like BatchExist for Delete.
+func BatchDelete(ctx context.Context, s FileStoreBase, paths []Path, opts ...BatchOption) []DeleteResult {
+	ret := make([]DeleteResult, len(paths))
+	forEach(ctx, len(paths), NewBatchOptions(opts...).Concurrency, func(i int) {
+		ret[i] = DeleteResult{Path: paths[i], Err: s.Delete(ctx, paths[i])}
+	})
+	for i, v := range ret {
+		if v.Path == nil {
+			ret[i] = DeleteResult{Path: paths[i], Err: ctx.Err()}
+		}
+	}
+	return ret
+}
+
+// AllExist reports whether all paths exist, errors of the results are
+// joined.
+func AllExist(results []ExistResult) (bool, error) {
+	all := true
+	var errs []error
+	for _, v := range results {
+		all = all && v.Exist
+		if v.Err != nil {
+			errs = append(errs, v.Err)
+		}
+	}
+	return all, errors.Join(errs...)
+}
+
+func (f *fileStore) ExistMany(ctx context.Context, paths []Path, opts ...BatchOption) []ExistResult {
+	return BatchExist(ctx, f, paths, opts...)
+}
+
+func (f *fileStore) StatMany(ctx context.Context, paths []Path, opts ...BatchOption) []StatResult {
+	return BatchStat(ctx, f, paths, opts...)
+}
+
+func (f *fileStore) DeleteMany(ctx context.Context, paths []Path, opts ...BatchOption) []DeleteResult {
+	return BatchDelete(ctx, f, paths, opts...)
+}
@@ -0,0 +1,89 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Capabilities describe which optional features a driver supports
+// natively. FileStorage emulates missing ones where it is possible and
+// returns ErrUnsupported otherwise.
+type Capabilities struct {
+	// Copy is a copy without streaming the content through the process.
+	Copy bool
+	// Rename is an atomic move of a file.
+	Rename bool
+	// RangeRead is a read starting at an offset without reading
+	// the preceding content.
+	RangeRead bool
+	// Append means the driver is an Appender which does not rewrite
+	// a file.
+	Append bool
+	// Metadata means the driver is a MetadataStore and it stores metadata
+	// of write options.
+	Metadata bool
+	// Preconditions are checked atomically with a write.
+	Preconditions bool
+	// Versioning means FileInfo.Generation identifies a content version.
+	Versioning bool
+	// Watch means the driver is a Watcher with native notifications,
+	// other drivers are watched by polling.
+	Watch bool
+}
+
+func (f *fileStore) Capabilities(p Path) (Capabilities, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return d.Capabilities(), nil
+}
+
+// emulateRangeReader skips offset bytes of a whole file reader.
+func emulateRangeReader(ctx context.Context, d StorageDriver, p Path,
+	offset, length int64, opts ...ReadOption) (io.ReadCloser, error) {
+
+	r, err := d.NewReader(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil && err != io.EOF {
+		r.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return r, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, length), r}, nil
+}
+
+// emulatePreconditions checks write preconditions with Stat. Unlike
+// native preconditions it is not atomic with a write.
+func emulatePreconditions(ctx context.Context, d StorageDriver, p Path, o WriteOptions) error {
+	if !o.IfNotExist && o.IfGenerationMatch == 0 && o.IfModTimeMatch.IsZero() {
+		return nil
+	}
+	if o.IfGenerationMatch != 0 && !d.Capabilities().Versioning {
+		return &Error{Op: "create", Path: p, Kind: ErrUnsupported}
+	}
+	info, err := d.Stat(ctx, p)
+	if errors.Is(err, ErrNotExist) {
+		if o.IfNotExist {
+			return nil
+		}
+		return &Error{Op: "create", Path: p, Kind: ErrPrecondition, Err: err}
+	} else if err != nil {
+		return err
+	}
+	if o.IfNotExist {
+		return &Error{Op: "create", Path: p, Kind: ErrPrecondition}
+	}
+	if err := o.CheckPreconditions(info); err != nil {
+		return &Error{Op: "create", Path: p, Kind: err}
+	}
+	return nil
+}
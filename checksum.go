@@ -0,0 +1,119 @@
+package filab
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// CRC32CTable is the Castagnoli table used for CRC32C checksums.
+var CRC32CTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksums of a content. A nil MD5 and a zero CRC32C mean they are
+// unknown.
+type Checksums struct {
+	MD5    []byte
+	CRC32C uint32
+}
+
+// ChecksumsOf returns checksums a driver reported in info.
+func ChecksumsOf(info FileInfo) Checksums {
+	return Checksums{MD5: info.MD5, CRC32C: info.CRC32C}
+}
+
+// IsZero reports whether no checksum is known.
+func (c Checksums) IsZero() bool {
+	return c.MD5 == nil && c.CRC32C == 0
+}
+
+// Matches reports whether computed checksums c are equal to the known
+// ones of stored.
+func (c Checksums) Matches(stored Checksums) bool {
+	if stored.MD5 != nil {
+		return bytes.Equal(c.MD5, stored.MD5) &&
+			(stored.CRC32C == 0 || c.CRC32C == stored.CRC32C)
+	}
+	return c.CRC32C == stored.CRC32C
+}
+
+// Hasher computes checksums of written bytes.
+type Hasher struct {
+	md5  hash.Hash
+	crc  hash.Hash32
+	size int64
+}
+
+func NewHasher() *Hasher {
+	return &Hasher{md5: md5.New(), crc: crc32.New(CRC32CTable)}
+}
+
+func (h *Hasher) Write(b []byte) (int, error) {
+	h.md5.Write(b)
+	h.crc.Write(b)
+	h.size += int64(len(b))
+	return len(b), nil
+}
+
+// Size returns a number of written bytes.
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+func (h *Hasher) Checksums() Checksums {
+	return Checksums{MD5: h.md5.Sum(nil), CRC32C: h.crc.Sum32()}
+}
+
+// verifyingReader checksums a content and compares it on Close with
+// checksums reported by Stat before the read.
+type verifyingReader struct {
+	io.ReadCloser
+	p    Path
+	want Checksums
+	h    *Hasher
+	eof  bool
+}
+
+func (r *verifyingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.h.Write(b[:n])
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// Close fails with ErrChecksum on a mismatch. A content which was not read
+// to the end is not verified.
+func (r *verifyingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.eof && !r.h.Checksums().Matches(r.want) {
+		return &Error{Op: "read", Path: r.p, Kind: ErrChecksum}
+	}
+	return err
+}
+
+// newVerifyingReader opens p with a reader verifying its checksums,
+// it fails with ErrUnsupported if a driver does not know them.
+func newVerifyingReader(ctx context.Context, d StorageDriver, p Path, opts ...ReadOption) (io.ReadCloser, error) {
+	info, err := d.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	want := ChecksumsOf(info)
+	if want.IsZero() {
+		return nil, &Error{Op: "open", Path: p, Kind: ErrUnsupported}
+	}
+	r, err := d.NewReader(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := r.(ContentEncoder); ok && info.ContentEncoding != "" && e.ContentEncoding() == "" {
+		// A driver decoded the content, the checksums are of the stored one.
+		r.Close()
+		return nil, &Error{Op: "open", Path: p, Kind: ErrUnsupported}
+	}
+	return &verifyingReader{ReadCloser: r, p: p, want: want, h: NewHasher()}, nil
+}
@@ -0,0 +1,350 @@
+package filab
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/datainq/rwmc"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// DefaultLevel passed to a codec writer means the codec's own default.
+const DefaultLevel = -1
+
+// parallelBlockSize is a size of a block compressed by one goroutine of
+// a parallel writer.
+const parallelBlockSize = 1 << 20
+
+// Codec compresses and decompresses files with a given suffix.
+type Codec struct {
+	Name   string
+	Suffix string
+	// Level is passed to NewWriter.
+	Level     int
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// NewWriter is nil for codecs which can only read.
+	NewWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	// NewParallelWriter compresses with n goroutines, it may be nil.
+	// See WithParallelism.
+	NewParallelWriter func(w io.Writer, level, n int) (io.WriteCloser, error)
+	// Detect reports whether a header of a stream was written by the codec,
+	// it may be nil. See DetectCompression.
+	Detect func(header []byte) bool
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+	// order of names is the registration order, detection follows it.
+	order []string
+}{byName: make(map[string]Codec)}
+
+func init() {
+	for _, c := range []Codec{
+		{
+			Name:   "gzip",
+			Suffix: ".gz",
+			Level:  gzip.BestCompression,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+			NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+				return gzip.NewWriterLevel(w, level)
+			},
+			NewParallelWriter: func(w io.Writer, level, n int) (io.WriteCloser, error) {
+				pw, err := pgzip.NewWriterLevel(w, level)
+				if err != nil {
+					return nil, err
+				}
+				if err := pw.SetConcurrency(parallelBlockSize, n); err != nil {
+					return nil, err
+				}
+				return pw, nil
+			},
+			Detect: hasMagic("\x1f\x8b"),
+		},
+		{
+			Name:   "zstd",
+			Suffix: ".zst",
+			Level:  DefaultLevel,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				d, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return d.IOReadCloser(), nil
+			},
+			NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+				if level == DefaultLevel {
+					return zstd.NewWriter(w)
+				}
+				return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+			},
+			NewParallelWriter: func(w io.Writer, level, n int) (io.WriteCloser, error) {
+				opts := []zstd.EOption{zstd.WithEncoderConcurrency(n)}
+				if level != DefaultLevel {
+					opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+				}
+				return zstd.NewWriter(w, opts...)
+			},
+			Detect: hasMagic("\x28\xb5\x2f\xfd"),
+		},
+		{
+			// The framed snappy format.
+			Name:   "snappy",
+			Suffix: ".sz",
+			Level:  DefaultLevel,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(s2.NewReader(r)), nil
+			},
+			NewWriter: func(w io.Writer, _ int) (io.WriteCloser, error) {
+				return s2.NewWriter(w, s2.WriterSnappyCompat()), nil
+			},
+			Detect: hasMagic("\xff\x06\x00\x00sNaPpY"),
+		},
+		{
+			Name:   "bzip2",
+			Suffix: ".bz2",
+			Level:  DefaultLevel,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(bzip2.NewReader(r)), nil
+			},
+			Detect: hasMagic("BZh"),
+		},
+		{
+			Name:   "xz",
+			Suffix: ".xz",
+			Level:  DefaultLevel,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				x, err := xz.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return io.NopCloser(x), nil
+			},
+			NewWriter: func(w io.Writer, _ int) (io.WriteCloser, error) {
+				return xz.NewWriter(w)
+			},
+			Detect: hasMagic("\xfd7zXZ\x00"),
+		},
+		{
+			Name:   "lz4",
+			Suffix: ".lz4",
+			Level:  DefaultLevel,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(lz4.NewReader(r)), nil
+			},
+			NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+				lw := lz4.NewWriter(w)
+				if level > 0 && level <= 9 {
+					l := lz4.CompressionLevel(1 << (8 + level))
+					if err := lw.Apply(lz4.CompressionLevelOption(l)); err != nil {
+						return nil, err
+					}
+				}
+				return lw, nil
+			},
+			Detect: hasMagic("\x04\x22\x4d\x18"),
+		},
+		{
+			// Historically files with the 7z suffix hold zlib streams.
+			// It is detected last, its header is the weakest signature.
+			Name:   "zlib",
+			Suffix: ".7z",
+			Level:  zlib.BestCompression,
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return zlib.NewReader(r)
+			},
+			NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+				return zlib.NewWriterLevel(w, level)
+			},
+			Detect: isZlib,
+		},
+	} {
+		if err := RegisterCodec(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterCodec adds a codec, it fails if a codec with the same name or
+// suffix exists.
+func RegisterCodec(c Codec) error {
+	if c.Name == "" || c.Name == NoCompression || c.Suffix == "" || c.NewReader == nil {
+		return fmt.Errorf("filab: invalid codec %q", c.Name)
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	for _, v := range codecs.byName {
+		if v.Name == c.Name || v.Suffix == c.Suffix {
+			return fmt.Errorf("filab: codec %q: %w", c.Name, ErrExist)
+		}
+	}
+	codecs.byName[c.Name] = c
+	codecs.order = append(codecs.order, c.Name)
+	return nil
+}
+
+// SetCodecLevel changes the level a registered codec writes with.
+func SetCodecLevel(name string, level int) error {
+	codecs.Lock()
+	defer codecs.Unlock()
+	c, ok := codecs.byName[name]
+	if !ok {
+		return fmt.Errorf("filab: codec %q: %w", name, ErrNotExist)
+	}
+	c.Level = level
+	codecs.byName[name] = c
+	return nil
+}
+
+// CodecByName returns a registered codec.
+func CodecByName(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.byName[name]
+	return c, ok
+}
+
+// CodecForFile returns a codec with the longest suffix matching a file name.
+func CodecForFile(file string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	var ret Codec
+	for _, v := range codecs.byName {
+		if strings.HasSuffix(file, v.Suffix) && len(v.Suffix) > len(ret.Suffix) {
+			ret = v
+		}
+	}
+	return ret, ret.Name != ""
+}
+
+// Codecs returns sorted names of registered codecs.
+func Codecs() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	ret := make([]string, 0, len(codecs.byName))
+	for k := range codecs.byName {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// compressionName returns a codec name for a file, an override takes
+// precedence over the suffix.
+func compressionName(file, override string) string {
+	if override != "" {
+		return override
+	}
+	if c, ok := CodecForFile(file); ok {
+		return c.Name
+	}
+	return NoCompression
+}
+
+// compressOptions are write options of a codec writer.
+type compressOptions struct {
+	// level is used if levelSet, otherwise the level of a codec.
+	level       int
+	levelSet    bool
+	parallelism int
+}
+
+// addCompression wraps w with a codec writer. Close of the returned writer
+// closes both.
+func addCompression(name string, w io.WriteCloser, o compressOptions) (io.WriteCloser, error) {
+	if name == NoCompression {
+		return w, nil
+	}
+	c, ok := CodecByName(name)
+	if !ok {
+		return nil, fmt.Errorf("filab: unknown compression %q: %w", name, ErrUnsupported)
+	}
+	if c.NewWriter == nil {
+		return nil, fmt.Errorf("filab: compression %q cannot write: %w", name, ErrUnsupported)
+	}
+	level := c.Level
+	if o.levelSet {
+		level = o.level
+	}
+	var w1 io.WriteCloser
+	var err error
+	if o.parallelism > 1 && c.NewParallelWriter != nil {
+		w1, err = c.NewParallelWriter(w, level, o.parallelism)
+	} else {
+		w1, err = c.NewWriter(w, level)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rwmc.NewWriteMultiCloser(w1, w), nil
+}
+
+// checkCompression returns an error addCompression would fail with, so it
+// can be reported before a file is opened.
+func checkCompression(name string, o compressOptions) error {
+	if name == NoCompression {
+		return nil
+	}
+	w, err := addCompression(name, nopWriteCloser{io.Discard}, o)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// discardWriter releases a writer which content is not wanted, an atomic
+// writer is aborted instead of committed.
+func discardWriter(w io.WriteCloser) {
+	if a, ok := w.(AtomicWriter); ok {
+		a.Abort()
+		return
+	}
+	w.Close()
+}
+
+// addDecompression wraps r with a codec reader. Close of the returned
+// reader closes both.
+func addDecompression(name string, r io.ReadCloser) (io.ReadCloser, error) {
+	if name == NoCompression {
+		return r, nil
+	}
+	c, ok := CodecByName(name)
+	if !ok {
+		return nil, fmt.Errorf("filab: unknown compression %q: %w", name, ErrUnsupported)
+	}
+	r1, err := c.NewReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return rwmc.NewReadMultiCloser(r1, r), nil
+}
+
+func MaybeAddCompression(file string, w io.WriteCloser) (io.WriteCloser, error) {
+	return addCompression(compressionName(file, ""), w, compressOptions{})
+}
+
+func MaybeAddDecompression(file string, r io.ReadCloser) (io.ReadCloser, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return addDecompression(compressionName(file, ""), r)
+}
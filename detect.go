@@ -0,0 +1,112 @@
+package filab
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+)
+
+// DetectCompression passed to WithCompression of a read makes the
+// S-methods choose a codec from the content encoding reported by a driver
+// or from the magic bytes of the stream instead of a file suffix.
+const DetectCompression = "detect"
+
+// headerSize is the number of bytes peeked to detect a codec.
+const headerSize = 512
+
+// ContentEncoder is implemented by readers of drivers which store
+// a content encoding of a file, e.g. an object with Content-Encoding: gzip.
+type ContentEncoder interface {
+	// ContentEncoding returns the stored encoding of the returned content,
+	// empty if it is unknown or already decoded.
+	ContentEncoding() string
+}
+
+// encodingCodecs maps HTTP content encodings to codec names when they differ.
+var encodingCodecs = map[string]string{
+	"identity": NoCompression,
+	"deflate":  "zlib",
+	"x-gzip":   "gzip",
+}
+
+// codecForEncoding returns a codec name of a content encoding.
+func codecForEncoding(enc string) (string, bool) {
+	enc = strings.ToLower(strings.TrimSpace(enc))
+	if name, ok := encodingCodecs[enc]; ok {
+		return name, true
+	}
+	if _, ok := CodecByName(enc); ok {
+		return enc, true
+	}
+	return "", false
+}
+
+// detectCodec returns a name of the first codec in the registration order
+// which header matches.
+func detectCodec(header []byte) (string, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	for _, name := range codecs.order {
+		if v := codecs.byName[name]; v.Detect != nil && v.Detect(header) {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
+
+// detectDecompression wraps r with a codec reader chosen from the content
+// encoding of r, the magic bytes and, for codecs without Detect, the suffix
+// of a file.
+func detectDecompression(file string, r io.ReadCloser) (io.ReadCloser, error) {
+	if e, ok := r.(ContentEncoder); ok {
+		if name, ok := codecForEncoding(e.ContentEncoding()); ok {
+			return addDecompression(name, r)
+		}
+	}
+	br := bufio.NewReader(r)
+	header, err := br.Peek(headerSize)
+	if err != nil && err != io.EOF {
+		r.Close()
+		return nil, err
+	}
+	r1 := struct {
+		io.Reader
+		io.Closer
+	}{br, r}
+	if name, ok := detectCodec(header); ok {
+		return addDecompression(name, r1)
+	}
+	if c, ok := CodecForFile(file); ok && c.Detect == nil {
+		return addDecompression(c.Name, r1)
+	}
+	return r1, nil
+}
+
+// MaybeDetectDecompression is like MaybeAddDecompression but it detects
+// a codec from the content, see DetectCompression.
+func MaybeDetectDecompression(file string, r io.ReadCloser) (io.ReadCloser, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return detectDecompression(file, r)
+}
+
+func hasMagic(magic string) func([]byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte(magic))
+	}
+}
+
+// isZlib checks a zlib header: the deflate method, the window size, the check
+// bits and no preset dictionary. The two bytes are common in plain text
+// too, so the rest of the header must also inflate without errors.
+func isZlib(header []byte) bool {
+	if len(header) < 2 || header[0]&0x0f != 8 || header[0]>>4 > 7 ||
+		header[1]&0x20 != 0 || (uint16(header[0])<<8|uint16(header[1]))%31 != 0 {
+		return false
+	}
+	_, err := io.CopyN(io.Discard, flate.NewReader(bytes.NewReader(header[2:])), 1<<16)
+	return err == nil || err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,49 @@
+package filab
+
+import "errors"
+
+// Driver-neutral kinds of errors. Drivers wrap their native errors with
+// Error, so errors.Is works the same way for every backend and still
+// matches the native error.
+var (
+	ErrNotExist     = errors.New("file does not exist")
+	ErrExist        = errors.New("file already exists")
+	ErrPermission   = errors.New("permission denied")
+	ErrPrecondition = errors.New("precondition failed")
+	ErrUnsupported  = errors.New("operation not supported")
+	ErrChecksum     = errors.New("checksum mismatch")
+	// ErrNoDriver is returned for paths and names of unregistered drivers.
+	ErrNoDriver = errors.New("no driver registered")
+)
+
+// Error records an operation and a path which caused a driver error.
+type Error struct {
+	Op   string
+	Path Path
+	// Kind is one of the Err* values of this package or nil.
+	Kind error
+	// Err is a native error of a driver, it may be nil if there is only Kind.
+	Err error
+}
+
+func (e *Error) Error() string {
+	err := e.Err
+	if err == nil {
+		err = e.Kind
+	}
+	if e.Path == nil {
+		return e.Op + ": " + err.Error()
+	}
+	return e.Op + " " + e.Path.String() + ": " + err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	var ret []error
+	if e.Kind != nil {
+		ret = append(ret, e.Kind)
+	}
+	if e.Err != nil {
+		ret = append(ret, e.Err)
+	}
+	return ret
+}
@@ -1,13 +1,11 @@
 package filab
 
 import (
-	"compress/gzip"
-	"compress/zlib"
 	"context"
+	"errors"
 	"io"
-	"strings"
+	"sync"
 
-	"github.com/datainq/rwmc"
 	"github.com/orian/pbio"
 )
 
@@ -15,38 +13,151 @@ const DefaultProtoMaxSize = 10000000
 
 type DriverType *string
 
-type WalkFunc func(p Path, err error) error
-
 type FileStoreBase interface {
 	Parse(s string) (Path, error)
 	Exist(context.Context, Path) (bool, error)
+	Stat(context.Context, Path) (FileInfo, error)
 	Delete(context.Context, Path) error
-	NewReader(context.Context, Path) (io.ReadCloser, error)
-	NewWriter(context.Context, Path) (io.WriteCloser, error)
+	NewReader(context.Context, Path, ...ReadOption) (io.ReadCloser, error)
+	// NewRangeReader reads length bytes starting at offset. If length is
+	// negative, it reads until the end of the file.
+	NewRangeReader(ctx context.Context, p Path, offset, length int64, opts ...ReadOption) (io.ReadCloser, error)
+	NewWriter(context.Context, Path, ...WriteOption) (io.WriteCloser, error)
+	// NewAtomicWriter returns a writer which content is visible only after
+	// a successful Commit (or Close).
+	NewAtomicWriter(context.Context, Path, ...WriteOption) (AtomicWriter, error)
 	List(context.Context, Path) ([]Path, error)
+	ListIter(context.Context, Path, ListOptions) (ListIterator, error)
+	// ReadDir returns direct children of a directory sorted by name.
+	// Subdirectories (common prefixes on object stores) have IsDir set.
+	ReadDir(context.Context, Path) ([]FileInfo, error)
 	Walk(context.Context, Path, WalkFunc) error
 }
 
+// AtomicWriter never leaves a partially written file. Close is equivalent
+// to Commit. Abort discards the content, it is a no-op after Commit,
+// so it can be deferred.
+type AtomicWriter interface {
+	io.WriteCloser
+	Commit() error
+	Abort() error
+}
+
 type StorageDriver interface {
 	Name() string
 	Scheme() string
 	Type() DriverType
+	Capabilities() Capabilities
+
+	// Init prepares a driver, e.g. connects to a service. Drivers must
+	// also work without Init, initialising lazily.
+	Init(ctx context.Context) error
+	// Close releases resources held by a driver.
+	Close() error
 
 	FileStoreBase
 }
 
+// Copier is implemented by drivers which can copy a file without
+// streaming its content through the process.
+type Copier interface {
+	Copy(ctx context.Context, dst, src Path) error
+}
+
+// Renamer is implemented by drivers which can move a file without
+// streaming its content through the process. It does not need to be
+// atomic, see Capabilities.Rename.
+type Renamer interface {
+	Rename(ctx context.Context, dst, src Path) error
+}
+
 type FileStorage interface {
+	// RegisterDriver adds a driver, it fails if a driver with the same name,
+	// type or scheme is already registered, unless that one is a default
+	// driver registered by an import of a driver package. The replaced
+	// driver is closed.
 	RegisterDriver(driver StorageDriver) error
+	// ReplaceDriver adds a driver replacing and closing one registered with
+	// the same name. It fails if the type or scheme of another driver
+	// is the same.
+	ReplaceDriver(driver StorageDriver) error
+	// Driver returns a registered driver by name.
+	Driver(name string) (StorageDriver, error)
+	// Drivers returns sorted names of registered drivers.
+	Drivers() []string
+	// Capabilities returns capabilities of a driver handling a path.
+	Capabilities(p Path) (Capabilities, error)
+
+	// Init initialises all registered drivers in the registration order.
+	Init(ctx context.Context) error
+	// Close closes all registered drivers in the reverse registration order.
+	Close() error
 
 	FileStoreBase
 
+	// OpenSeekable returns a seekable reader of p, native if a driver is
+	// a SeekableOpener, otherwise backed by range reads. The content is
+	// not decompressed.
+	OpenSeekable(ctx context.Context, p Path, opts ...ReadOption) (io.ReadSeekCloser, error)
+
+	// NewAppender returns a writer appending to p, it creates a missing
+	// file. Drivers without Append capability rewrite the whole file.
+	NewAppender(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error)
+
+	// Copy copies src to dst. A driver's Copier is used if both paths
+	// belong to the same driver, otherwise the content is streamed.
+	Copy(ctx context.Context, dst, src Path) error
+	// Rename moves src to dst. A driver's Renamer is used if both paths
+	// belong to the same driver, otherwise src is copied and deleted.
+	Rename(ctx context.Context, dst, src Path) error
+
+	// GetMetadata and SetMetadata access attributes stored with a file,
+	// they fail with ErrUnsupported for drivers without Metadata capability.
+	GetMetadata(ctx context.Context, p Path) (Metadata, error)
+	SetMetadata(ctx context.Context, p Path, m Metadata) error
+
+	// ExistMany, StatMany and DeleteMany run an operation on many paths,
+	// possibly of different drivers, in parallel. Results are in the order
+	// of paths.
+	ExistMany(ctx context.Context, paths []Path, opts ...BatchOption) []ExistResult
+	StatMany(ctx context.Context, paths []Path, opts ...BatchOption) []StatResult
+	DeleteMany(ctx context.Context, paths []Path, opts ...BatchOption) []DeleteResult
+
+	// RemoveAll removes p and everything below it. Drivers without
+	// a TreeRemover have files listed and deleted in parallel.
+	RemoveAll(ctx context.Context, p Path) error
+	// MkdirAll creates a directory with parents on drivers with real
+	// directories.
+	MkdirAll(ctx context.Context, p Path) error
+
+	// TempFile returns a writer of a new file in dir with a unique name,
+	// the last * of pattern is replaced by a random string. TempDir creates
+	// a directory the same way. They are removed by Remove of the returned
+	// handle or when ctx is done.
+	TempFile(ctx context.Context, dir Path, pattern string, opts ...WriteOption) (*TempWriter, error)
+	TempDir(ctx context.Context, dir Path, pattern string) (*Temp, error)
+
+	// Watch emits changes of files below p until ctx is done. A driver's
+	// Watcher is used if it has native notifications, otherwise listings
+	// are polled.
+	Watch(ctx context.Context, p Path, opts ...WatchOption) (<-chan Event, error)
+
+	// Glob returns files matching a pattern with *, ?, [...], {a,b} and **.
+	Glob(ctx context.Context, pattern string) ([]Path, error)
+
 	MustParse(p string) Path
 
-	NewReaderS(p Path) (io.ReadCloser, error)
-	NewPbReaderS(p Path) (pbio.ReadCloser, error)
+	NewReaderS(p Path, opts ...ReadOption) (io.ReadCloser, error)
+	NewPbReaderS(p Path, opts ...ReadOption) (pbio.ReadCloser, error)
 
-	NewWriterS(p Path) (io.WriteCloser, error)
-	NewPbWriterS(p Path) (pbio.WriteCloser, error)
+	NewWriterS(p Path, opts ...WriteOption) (io.WriteCloser, error)
+	NewPbWriterS(p Path, opts ...WriteOption) (pbio.WriteCloser, error)
+
+	// SetCompressionLevel sets a level of a codec used by the S-methods
+	// of this FileStorage, WithCompressionLevel takes precedence.
+	SetCompressionLevel(codec string, level int)
+	// AddCompression wraps w with a codec chosen like by NewWriterS.
+	AddCompression(p Path, w io.WriteCloser, opts ...WriteOption) (io.WriteCloser, error)
 }
 
 var defaultStore = New()
@@ -57,70 +168,118 @@ func DefaultFileStore() FileStorage {
 
 func New() FileStorage {
 	return &fileStore{
-		bySchemaPrefix:  make(map[string]StorageDriver),
+		byName:          make(map[string]StorageDriver),
 		byType:          make(map[DriverType]StorageDriver),
+		levels:          make(map[string]int),
+		defaults:        make(map[string]bool),
 		ProtoMaxSize:    DefaultProtoMaxSize,
 		AutoCompression: true,
 	}
 }
 
 type fileStore struct {
-	bySchemaPrefix map[string]StorageDriver
-	byType         map[DriverType]StorageDriver
-	local          StorageDriver
+	m      sync.RWMutex
+	byName map[string]StorageDriver
+	byType map[DriverType]StorageDriver
+	// drivers are in the registration order.
+	drivers []StorageDriver
+	// schemes are sorted by a prefix length, the longest first.
+	schemes []schemeDriver
+	local   StorageDriver
+	// defaults are names of default drivers, see RegisterDefaultDriver.
+	defaults map[string]bool
+	// levels of codecs by a name.
+	levels map[string]int
 
 	ProtoMaxSize    int
 	AutoCompression bool
 }
 
-func (f *fileStore) NewReaderS(p Path) (io.ReadCloser, error) {
-	r, err := f.NewReader(context.Background(), p)
+func (f *fileStore) NewReaderS(p Path, opts ...ReadOption) (io.ReadCloser, error) {
+	r, err := f.NewReader(context.Background(), p, opts...)
 	if err != nil || !f.AutoCompression {
 		return r, err
 	}
-	if strings.HasSuffix(p.String(), ".7z") {
-		r, err = zlib.NewReader(r)
-	} else if strings.HasSuffix(p.String(), ".gz") {
-		r, err = gzip.NewReader(r)
+	o := NewReadOptions(opts...)
+	if o.Compression == DetectCompression {
+		return detectDecompression(p.String(), r)
 	}
-	return r, err
+	return addDecompression(compressionName(p.String(), o.Compression), r)
 }
 
-func (f *fileStore) NewPbReaderS(p Path) (pbio.ReadCloser, error) {
-	r, err := f.NewReaderS(p)
+func (f *fileStore) NewPbReaderS(p Path, opts ...ReadOption) (pbio.ReadCloser, error) {
+	r, err := f.NewReaderS(p, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return pbio.NewDelimitedReader(r, f.ProtoMaxSize), nil
 }
 
-func (f *fileStore) NewWriterS(p Path) (io.WriteCloser, error) {
-	w, err := f.NewWriter(context.Background(), p)
-	if err != nil || !f.AutoCompression {
-		return w, err
+func (f *fileStore) NewWriterS(p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	name, co := f.compressOptions(p, opts)
+	// A codec is checked before a file is opened, opening may truncate it.
+	if err := checkCompression(name, co); err != nil {
+		return nil, err
+	}
+	w, err := f.NewWriter(context.Background(), p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	w1, err := addCompression(name, w, co)
+	if err != nil {
+		discardWriter(w)
+		return nil, err
+	}
+	return w1, nil
+}
+
+func (f *fileStore) SetCompressionLevel(codec string, level int) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.levels[codec] = level
+}
+
+func (f *fileStore) AddCompression(p Path, w io.WriteCloser, opts ...WriteOption) (io.WriteCloser, error) {
+	name, co := f.compressOptions(p, opts)
+	return addCompression(name, w, co)
+}
+
+// compressOptions returns a codec name and options of a writer of p.
+func (f *fileStore) compressOptions(p Path, opts []WriteOption) (string, compressOptions) {
+	if !f.AutoCompression {
+		return NoCompression, compressOptions{}
 	}
-	return MaybeAddCompression(p.String(), w)
+	o := NewWriteOptions(opts...)
+	name := compressionName(p.String(), o.Compression)
+	co := compressOptions{
+		level:       o.CompressionLevel,
+		levelSet:    o.CompressionLevelSet,
+		parallelism: o.Parallelism,
+	}
+	if !co.levelSet {
+		f.m.RLock()
+		co.level, co.levelSet = f.levels[name]
+		f.m.RUnlock()
+	}
+	return name, co
 }
 
-func (f *fileStore) NewPbWriterS(p Path) (pbio.WriteCloser, error) {
-	w, err := f.NewWriterS(p)
+func (f *fileStore) NewPbWriterS(p Path, opts ...WriteOption) (pbio.WriteCloser, error) {
+	w, err := f.NewWriterS(p, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return pbio.NewDelimitedWriter(w), nil
 }
 
+// Parse uses a driver with the longest matching scheme prefix or the local
+// driver if no scheme matches.
 func (f *fileStore) Parse(s string) (Path, error) {
-	for k, v := range f.bySchemaPrefix {
-		if strings.HasPrefix(s, k) {
-			g, err := v.Parse(s)
-			if err != nil {
-				return nil, err
-			}
-			return g, nil
-		}
+	d, err := f.driverForString(s)
+	if err != nil {
+		return nil, err
 	}
-	return f.local.Parse(s)
+	return d.Parse(s)
 }
 
 func (f *fileStore) MustParse(s string) Path {
@@ -132,65 +291,160 @@ func (f *fileStore) MustParse(s string) Path {
 }
 
 func (f *fileStore) Exist(ctx context.Context, p Path) (bool, error) {
-	return f.byType[p.Type()].Exist(ctx, p)
+	d, err := f.driver(p)
+	if err != nil {
+		return false, err
+	}
+	return d.Exist(ctx, p)
+}
+
+func (f *fileStore) Stat(ctx context.Context, p Path) (FileInfo, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return d.Stat(ctx, p)
 }
 
 func (f *fileStore) Delete(ctx context.Context, p Path) error {
-	return f.byType[p.Type()].Delete(ctx, p)
+	d, err := f.driver(p)
+	if err != nil {
+		return err
+	}
+	return d.Delete(ctx, p)
+}
+
+func (f *fileStore) NewReader(ctx context.Context, p Path, opts ...ReadOption) (io.ReadCloser, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if NewReadOptions(opts...).VerifyChecksum {
+		return newVerifyingReader(ctx, d, p, opts...)
+	}
+	return d.NewReader(ctx, p, opts...)
 }
 
-func (f *fileStore) NewReader(ctx context.Context, p Path) (io.ReadCloser, error) {
-	return f.byType[p.Type()].NewReader(ctx, p)
+func (f *fileStore) NewRangeReader(ctx context.Context, p Path, offset, length int64, opts ...ReadOption) (io.ReadCloser, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Capabilities().RangeRead {
+		return emulateRangeReader(ctx, d, p, offset, length, opts...)
+	}
+	return d.NewRangeReader(ctx, p, offset, length, opts...)
+}
+
+func (f *fileStore) NewWriter(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Capabilities().Preconditions {
+		if err := emulatePreconditions(ctx, d, p, NewWriteOptions(opts...)); err != nil {
+			return nil, err
+		}
+	}
+	return d.NewWriter(ctx, p, opts...)
 }
 
-func (f *fileStore) NewWriter(ctx context.Context, p Path) (io.WriteCloser, error) {
-	return f.byType[p.Type()].NewWriter(ctx, p)
+func (f *fileStore) NewAtomicWriter(ctx context.Context, p Path, opts ...WriteOption) (AtomicWriter, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Capabilities().Preconditions {
+		if err := emulatePreconditions(ctx, d, p, NewWriteOptions(opts...)); err != nil {
+			return nil, err
+		}
+	}
+	return d.NewAtomicWriter(ctx, p, opts...)
 }
 
 func (f *fileStore) List(ctx context.Context, p Path) ([]Path, error) {
-	return f.byType[p.Type()].List(ctx, p)
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	return d.List(ctx, p)
+}
+
+func (f *fileStore) ListIter(ctx context.Context, p Path, o ListOptions) (ListIterator, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	return d.ListIter(ctx, p, o)
+}
+
+func (f *fileStore) ReadDir(ctx context.Context, p Path) ([]FileInfo, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	return d.ReadDir(ctx, p)
 }
 
 func (f *fileStore) Walk(ctx context.Context, p Path, w WalkFunc) error {
-	return f.byType[p.Type()].Walk(ctx, p, w)
+	d, err := f.driver(p)
+	if err != nil {
+		return err
+	}
+	return d.Walk(ctx, p, w)
 }
 
-func (f *fileStore) RegisterDriver(driver StorageDriver) error {
-	scheme := driver.Scheme()
-	if scheme != "" {
-		f.bySchemaPrefix[scheme+"://"] = driver
-	} else {
-		f.local = driver
+func (f *fileStore) Copy(ctx context.Context, dst, src Path) error {
+	d, err := f.driver(src)
+	if err != nil {
+		return err
+	}
+	if c, ok := d.(Copier); ok && d.Capabilities().Copy && dst.Type() == src.Type() {
+		return c.Copy(ctx, dst, src)
 	}
-	f.byType[driver.Type()] = driver
-	return nil
+	return f.streamCopy(ctx, dst, src)
 }
 
-func MaybeAddCompression(file string, w io.WriteCloser) (io.WriteCloser, error) {
-	if strings.HasSuffix(file, ".7z") {
-		w1, err := zlib.NewWriterLevel(w, zlib.BestCompression)
-		if err != nil {
-			return w1, err
-		}
-		return rwmc.NewWriteMultiCloser(w1, w), nil
-	} else if strings.HasSuffix(file, ".gz") {
-		w1, err := gzip.NewWriterLevel(w, gzip.BestCompression)
-		if err != nil {
-			return w1, err
-		}
-		return rwmc.NewWriteMultiCloser(w1, w), nil
+func (f *fileStore) streamCopy(ctx context.Context, dst, src Path) error {
+	// The source is verified if its checksums are known, a known CRC32C
+	// is passed on, so a driver can reject a corrupt upload before it
+	// becomes visible.
+	var opts []WriteOption
+	r, err := f.NewReader(ctx, src, VerifyChecksum())
+	if errors.Is(err, ErrUnsupported) {
+		r, err = f.NewReader(ctx, src)
+	} else if v, ok := r.(*verifyingReader); ok && v.want.CRC32C != 0 {
+		opts = append(opts, WithCRC32C(v.want.CRC32C))
+	}
+	if err != nil {
+		return err
 	}
-	return w, nil
+	defer r.Close()
+
+	w, err := f.NewAtomicWriter(ctx, dst, opts...)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	return w.Commit()
 }
 
-func MaybeAddDecompression(file string, r io.ReadCloser) (io.ReadCloser, error) {
-	if r == nil {
-		return nil,nil
+func (f *fileStore) Rename(ctx context.Context, dst, src Path) error {
+	d, err := f.driver(src)
+	if err != nil {
+		return err
+	}
+	if r, ok := d.(Renamer); ok && dst.Type() == src.Type() {
+		return r.Rename(ctx, dst, src)
 	}
-	if strings.HasSuffix(file, ".7z") {
-		return zlib.NewReader(r)
-	} else if strings.HasSuffix(file, ".gz") {
-		return gzip.NewReader(r)
+	if err := f.Copy(ctx, dst, src); err != nil {
+		return err
 	}
-	return r, nil
+	return f.Delete(ctx, src)
 }
@@ -0,0 +1,585 @@
+package filab
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePath struct {
+	s string
+	t DriverType
+}
+
+func (p fakePath) Join(s ...string) Path { return p }
+func (p fakePath) String() string        { return p.s }
+func (p fakePath) Copy() Path            { return p }
+func (p fakePath) Dir() Path             { return p }
+func (p fakePath) Type() DriverType      { return p.t }
+func (p fakePath) DirStr() string        { return p.s }
+func (p fakePath) BaseStr() string       { return p.s }
+
+type fakeDriver struct {
+	StorageDriver
+	name, scheme string
+	typ          string
+}
+
+func (d *fakeDriver) Name() string     { return d.name }
+func (d *fakeDriver) Scheme() string   { return d.scheme }
+func (d *fakeDriver) Type() DriverType { return &d.typ }
+func (d *fakeDriver) Close() error     { return nil }
+func (d *fakeDriver) Parse(s string) (Path, error) {
+	return fakePath{d.name + ":" + s, d.Type()}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	s := New()
+	_, err := s.Parse("/file")
+	assert.True(t, errors.Is(err, ErrNoDriver))
+
+	local := &fakeDriver{name: "local"}
+	short := &fakeDriver{name: "short", scheme: "s"}
+	long := &fakeDriver{name: "long", scheme: "s3"}
+	for _, d := range []StorageDriver{local, short, long} {
+		assert.NoError(t, s.RegisterDriver(d))
+	}
+	assert.True(t, errors.Is(s.RegisterDriver(&fakeDriver{name: "local"}), ErrExist))
+	assert.True(t, errors.Is(s.RegisterDriver(&fakeDriver{name: "other", scheme: "s"}), ErrExist))
+	assert.Equal(t, []string{"local", "long", "short"}, s.Drivers())
+
+	for i := 0; i < 10; i++ {
+		p, err := s.Parse("s3://bucket/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "long:s3://bucket/file", p.String())
+	}
+	p, err := s.Parse("s://bucket/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "short:s://bucket/file", p.String())
+	p, err = s.Parse("/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "local:/file", p.String())
+
+	d, err := s.Driver("long")
+	assert.NoError(t, err)
+	assert.Equal(t, long, d)
+	_, err = s.Driver("unknown")
+	assert.True(t, errors.Is(err, ErrNoDriver))
+
+	_, err = s.Stat(context.Background(), fakePath{s: "unregistered"})
+	assert.True(t, errors.Is(err, ErrNoDriver))
+
+	replaced := &fakeDriver{name: "local", scheme: "l"}
+	assert.NoError(t, s.ReplaceDriver(replaced))
+	_, err = s.Parse("/file")
+	assert.True(t, errors.Is(err, ErrNoDriver))
+	p, err = s.Parse("l://file")
+	assert.NoError(t, err)
+	assert.Equal(t, "local:l://file", p.String())
+	assert.True(t, errors.Is(s.ReplaceDriver(&fakeDriver{name: "local", scheme: "s"}), ErrExist))
+}
+
+func TestRegisterDefaultDriver(t *testing.T) {
+	var closed []string
+	s := New().(*fileStore)
+	assert.NoError(t, s.registerDefault(&closeDriver{fakeDriver{name: "gs", scheme: "gs"}, &closed}))
+	assert.True(t, errors.Is(s.registerDefault(&fakeDriver{name: "gs", scheme: "gs"}), ErrExist))
+
+	// An explicit registration replaces a default driver.
+	configured := &closeDriver{fakeDriver{name: "gs", scheme: "gs"}, &closed}
+	assert.NoError(t, s.RegisterDriver(configured))
+	assert.Equal(t, []string{"gs"}, closed)
+	d, err := s.Driver("gs")
+	assert.NoError(t, err)
+	assert.Equal(t, configured, d)
+	assert.True(t, errors.Is(s.RegisterDriver(&fakeDriver{name: "gs", scheme: "gs"}), ErrExist))
+
+	assert.NoError(t, s.ReplaceDriver(&fakeDriver{name: "gs", scheme: "gs"}))
+	assert.Equal(t, []string{"gs", "gs"}, closed)
+}
+
+type memDriver struct {
+	fakeDriver
+	files map[string]string
+}
+
+func (d *memDriver) Capabilities() Capabilities { return Capabilities{} }
+
+func (d *memDriver) Stat(_ context.Context, p Path) (FileInfo, error) {
+	c, ok := d.files[p.String()]
+	if !ok {
+		return FileInfo{}, &Error{Op: "stat", Path: p, Kind: ErrNotExist}
+	}
+	return FileInfo{Path: p, Size: int64(len(c))}, nil
+}
+
+func (d *memDriver) NewReader(_ context.Context, p Path, _ ...ReadOption) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(d.files[p.String()])), nil
+}
+
+func (d *memDriver) NewWriter(_ context.Context, p Path, _ ...WriteOption) (io.WriteCloser, error) {
+	return nil, nil
+}
+
+func TestCapabilitiesEmulation(t *testing.T) {
+	s := New()
+	d := &memDriver{fakeDriver{name: "mem"}, map[string]string{"mem:file": "0123456789"}}
+	assert.NoError(t, s.RegisterDriver(d))
+	ctx := context.Background()
+	p := s.MustParse("file")
+
+	r, err := s.NewRangeReader(ctx, p, 3, 4)
+	assert.NoError(t, err)
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(b))
+
+	_, err = s.NewWriter(ctx, p, IfNotExist())
+	assert.True(t, errors.Is(err, ErrPrecondition))
+	_, err = s.NewWriter(ctx, s.MustParse("new"), IfNotExist())
+	assert.NoError(t, err)
+	_, err = s.NewWriter(ctx, p, IfGenerationMatch(1))
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
+
+// recordDriver reports checksums of its files and records options and
+// content of an atomic write.
+type recordDriver struct {
+	memDriver
+	opts    WriteOptions
+	written bufCloser
+}
+
+func (d *recordDriver) Stat(ctx context.Context, p Path) (FileInfo, error) {
+	info, err := d.memDriver.Stat(ctx, p)
+	h := NewHasher()
+	io.WriteString(h, d.files[p.String()])
+	c := h.Checksums()
+	info.MD5, info.CRC32C = c.MD5, c.CRC32C
+	return info, err
+}
+
+func (d *recordDriver) NewAtomicWriter(_ context.Context, _ Path, opts ...WriteOption) (AtomicWriter, error) {
+	d.opts = NewWriteOptions(opts...)
+	return nopAtomicWriter{&d.written}, nil
+}
+
+type nopAtomicWriter struct {
+	io.WriteCloser
+}
+
+func (nopAtomicWriter) Commit() error { return nil }
+func (nopAtomicWriter) Abort() error  { return nil }
+
+func TestStreamCopyCRC32C(t *testing.T) {
+	s := New()
+	src := &recordDriver{memDriver: memDriver{fakeDriver{name: "src"}, map[string]string{"src:file": "content"}}}
+	dst := &recordDriver{memDriver: memDriver{fakeDriver{name: "dst", scheme: "dst"}, nil}}
+	assert.NoError(t, s.RegisterDriver(src))
+	assert.NoError(t, s.RegisterDriver(dst))
+
+	assert.NoError(t, s.Copy(context.Background(), s.MustParse("dst://file"), s.MustParse("file")))
+	assert.Equal(t, "content", dst.written.String())
+	assert.True(t, dst.opts.SendCRC32C)
+	assert.Equal(t, crc32.Checksum([]byte("content"), CRC32CTable), dst.opts.CRC32C)
+}
+
+type closeDriver struct {
+	fakeDriver
+	closed *[]string
+}
+
+func (d *closeDriver) Init(context.Context) error {
+	if d.name == "broken" {
+		return errors.New("cannot connect")
+	}
+	return nil
+}
+
+func (d *closeDriver) Close() error {
+	*d.closed = append(*d.closed, d.name)
+	return nil
+}
+
+func TestInitClose(t *testing.T) {
+	s := New()
+	var closed []string
+	for _, n := range []string{"a", "b", "c"} {
+		assert.NoError(t, s.RegisterDriver(&closeDriver{fakeDriver{name: n, scheme: n}, &closed}))
+	}
+	assert.NoError(t, s.Init(context.Background()))
+	assert.NoError(t, s.Close())
+	assert.Equal(t, []string{"c", "b", "a"}, closed)
+
+	assert.NoError(t, s.RegisterDriver(&closeDriver{fakeDriver{name: "broken", scheme: "x"}, &closed}))
+	assert.Error(t, s.Init(context.Background()))
+}
+
+type bufCloser struct {
+	strings.Builder
+}
+
+func (b *bufCloser) Close() error { return nil }
+
+func TestCodecs(t *testing.T) {
+	const content = "some text, some text, some text"
+	for _, name := range Codecs() {
+		c, _ := CodecByName(name)
+		if c.NewWriter == nil {
+			_, err := MaybeAddCompression("file"+c.Suffix, &bufCloser{})
+			assert.True(t, errors.Is(err, ErrUnsupported), name)
+			continue
+		}
+		b := &bufCloser{}
+		w, err := MaybeAddCompression("file"+c.Suffix, b)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		_, err = io.WriteString(w, content)
+		assert.NoError(t, err, name)
+		assert.NoError(t, w.Close(), name)
+		assert.NotEqual(t, content, b.String(), name)
+
+		r, err := MaybeAddDecompression("file"+c.Suffix, io.NopCloser(strings.NewReader(b.String())))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err, name)
+		assert.Equal(t, content, string(got), name)
+		assert.NoError(t, r.Close(), name)
+	}
+
+	c, ok := CodecForFile("dir/file.json.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "gzip", c.Name)
+	_, ok = CodecForFile("dir/file.json")
+	assert.False(t, ok)
+	assert.True(t, errors.Is(RegisterCodec(Codec{
+		Name: "other", Suffix: ".gz", NewReader: c.NewReader}), ErrExist))
+}
+
+type encodedReader struct {
+	io.ReadCloser
+	enc string
+}
+
+func (r encodedReader) ContentEncoding() string { return r.enc }
+
+func TestDetectCompression(t *testing.T) {
+	const content = "some text, some text, some text"
+	for _, name := range Codecs() {
+		c, _ := CodecByName(name)
+		if c.NewWriter == nil {
+			continue
+		}
+		b := &bufCloser{}
+		w, err := addCompression(name, b, compressOptions{})
+		assert.NoError(t, err, name)
+		io.WriteString(w, content)
+		assert.NoError(t, w.Close(), name)
+
+		r, err := MaybeDetectDecompression("file", io.NopCloser(strings.NewReader(b.String())))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err, name)
+		assert.Equal(t, content, string(got), name)
+	}
+
+	// Not compressed content with a misleading suffix.
+	r, err := MaybeDetectDecompression("file.gz", io.NopCloser(strings.NewReader("ab")))
+	assert.NoError(t, err)
+	got, _ := io.ReadAll(r)
+	assert.Equal(t, "ab", string(got))
+
+	// Plain text with a valid zlib header.
+	for _, v := range []string{"80,apples\n", "x = 1\n"} {
+		r, err = MaybeDetectDecompression("file", io.NopCloser(strings.NewReader(v)))
+		assert.NoError(t, err, v)
+		got, err = io.ReadAll(r)
+		assert.NoError(t, err, v)
+		assert.Equal(t, v, string(got))
+	}
+
+	// A stored encoding wins over the content.
+	r, err = MaybeDetectDecompression("file", encodedReader{
+		io.NopCloser(strings.NewReader(content)), "identity"})
+	assert.NoError(t, err)
+	got, _ = io.ReadAll(r)
+	assert.Equal(t, content, string(got))
+}
+
+func TestCompressionLevel(t *testing.T) {
+	content := strings.Repeat("some text, some other text, ", 100000)
+	s := New()
+	p := fakePath{s: "file.gz"}
+	compress := func(opts ...WriteOption) string {
+		b := &bufCloser{}
+		w, err := s.AddCompression(p, b, opts...)
+		assert.NoError(t, err)
+		io.WriteString(w, content)
+		assert.NoError(t, w.Close())
+		return b.String()
+	}
+	best := compress()
+	none := compress(WithCompressionLevel(gzip.HuffmanOnly))
+	assert.Less(t, len(best), len(none))
+	s.SetCompressionLevel("gzip", gzip.HuffmanOnly)
+	assert.Equal(t, len(none), len(compress()))
+	assert.Less(t, len(compress(WithCompressionLevel(gzip.BestCompression))), len(none))
+	assert.Greater(t, len(compress(WithCompressionLevel(gzip.NoCompression))), len(content))
+
+	parallel := compress(WithCompressionLevel(gzip.BestSpeed), WithParallelism(4))
+	r, err := MaybeAddDecompression("file.gz", io.NopCloser(strings.NewReader(parallel)))
+	assert.NoError(t, err)
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(got))
+}
+
+func TestExpandBraces(t *testing.T) {
+	got, err := expandBraces("a/{b,c{d,e}}/{f,g}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/b/f", "a/b/g", "a/cd/f", "a/cd/g", "a/ce/f", "a/ce/g"}, got)
+	got, err = expandBraces(`a/\{b,c}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`a/\{b,c}`}, got)
+	_, err = expandBraces("a/{b")
+	assert.Error(t, err)
+}
+
+func TestMatchSegments(t *testing.T) {
+	for _, v := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*/*.gz", "2024/a.gz", true},
+		{"*/*.gz", "2024/01/a.gz", false},
+		{"**/*.gz", "a.gz", true},
+		{"**/*.gz", "2024/01/a.gz", true},
+		{"2024/**", "2024/01/a.gz", true},
+		{"a?c/[!x]", "abc/y", true},
+		{"a?c/[!x]", "abc/x", false},
+	} {
+		got := matchSegments(strings.Split(matchSyntax(v.pattern), "/"), strings.Split(v.name, "/"))
+		assert.Equal(t, v.want, got, "%s %s", v.pattern, v.name)
+	}
+	assert.True(t, matchDirSegments([]string{"*", "*.gz"}, []string{"2024"}))
+	assert.False(t, matchDirSegments([]string{"*", "*.gz"}, []string{"2024", "01"}))
+	assert.True(t, matchDirSegments([]string{"**", "*.gz"}, []string{"2024", "01"}))
+}
+
+// listDriver is an object store with a flat listing of a prefix only.
+type listDriver struct {
+	fakeDriver
+	files      []string
+	listed     []string
+	generation int64
+}
+
+func (d *listDriver) Parse(s string) (Path, error) { return fakePath{s, d.Type()}, nil }
+
+func (d *listDriver) Stat(_ context.Context, p Path) (FileInfo, error) {
+	for _, v := range d.files {
+		if v == p.String() {
+			return FileInfo{Path: p, Generation: d.generation}, nil
+		}
+	}
+	return FileInfo{}, &Error{Op: "stat", Path: p, Kind: ErrNotExist}
+}
+
+func (d *listDriver) ListIter(_ context.Context, p Path, _ ListOptions) (ListIterator, error) {
+	d.listed = append(d.listed, p.String())
+	var paths []Path
+	for _, v := range d.files {
+		if strings.HasPrefix(v, p.String()) {
+			paths = append(paths, fakePath{v, d.Type()})
+		}
+	}
+	return &sliceIterator{paths: paths}, nil
+}
+
+type sliceIterator struct {
+	paths []Path
+}
+
+func (it *sliceIterator) Next() (Path, error) {
+	if len(it.paths) == 0 {
+		return nil, Done
+	}
+	p := it.paths[0]
+	it.paths = it.paths[1:]
+	return p, nil
+}
+
+func (it *sliceIterator) PageToken() string { return "" }
+func (it *sliceIterator) Close() error      { it.paths = nil; return nil }
+
+func TestGlobObjectStore(t *testing.T) {
+	d := &listDriver{fakeDriver: fakeDriver{name: "mem", scheme: "mem"}, files: []string{
+		"mem://b/logs/2023-12/a.gz",
+		"mem://b/logs/2024-01/",
+		"mem://b/logs/2024-01/a.gz",
+		"mem://b/logs/2024-01/x/b.gz",
+		"mem://b/logs/2024-02/c.txt",
+	}}
+	s := New()
+	assert.NoError(t, s.RegisterDriver(d))
+	paths, err := s.Glob(context.Background(), "mem://b/logs/2024-*/**/*.gz")
+	assert.NoError(t, err)
+	var got []string
+	for _, p := range paths {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{"mem://b/logs/2024-01/a.gz", "mem://b/logs/2024-01/x/b.gz"}, got)
+	assert.Equal(t, []string{"mem://b/logs"}, d.listed)
+}
+
+func TestPollFilesObjectStore(t *testing.T) {
+	d := &listDriver{fakeDriver: fakeDriver{name: "mem", scheme: "mem"}, files: []string{
+		"mem://b/logs/",
+		"mem://b/logs/a.gz",
+		"mem://b/logs/2024/b.gz",
+	}, generation: 1}
+	ctx := context.Background()
+	prev, err := pollFiles(ctx, d, fakePath{"mem://b/logs/", d.Type()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mem://b/logs/"}, d.listed)
+
+	d.generation = 2
+	d.files = d.files[:2]
+	current, err := pollFiles(ctx, d, fakePath{"mem://b/logs/", d.Type()})
+	assert.NoError(t, err)
+	var got []string
+	for _, e := range diffFiles(prev, current) {
+		got = append(got, e.Type.String()+" "+e.Path.String())
+	}
+	assert.Equal(t, []string{"delete mem://b/logs/2024/b.gz", "modify mem://b/logs/a.gz"}, got)
+
+	// A single object.
+	current, err = pollFiles(ctx, d, fakePath{"mem://b/logs/a.gz", d.Type()})
+	assert.NoError(t, err)
+	assert.Len(t, current, 1)
+	assert.Contains(t, current, "mem://b/logs/a.gz")
+}
+
+type deleteDriver struct {
+	fakeDriver
+	m       sync.Mutex
+	deleted []string
+}
+
+func (d *deleteDriver) Delete(_ context.Context, p Path) error {
+	switch p.String() {
+	case "missing":
+		return &Error{Op: "delete", Path: p, Kind: ErrNotExist}
+	case "denied":
+		return &Error{Op: "delete", Path: p, Kind: ErrPermission}
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.deleted = append(d.deleted, p.String())
+	return nil
+}
+
+func TestDeleteFiles(t *testing.T) {
+	d := &deleteDriver{}
+	var files []Path
+	for _, n := range []string{"a", "missing", "b", "denied", "c"} {
+		files = append(files, fakePath{s: n})
+	}
+	err := DeleteFiles(context.Background(), d, files)
+	assert.True(t, errors.Is(err, ErrPermission))
+	assert.False(t, errors.Is(err, ErrNotExist))
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, d.deleted)
+	assert.NoError(t, DeleteFiles(context.Background(), d, nil))
+}
+
+type existDriver struct {
+	fakeDriver
+}
+
+func (d *existDriver) Exist(ctx context.Context, p Path) (bool, error) {
+	if p.String() == "broken" {
+		return false, errors.New("broken")
+	}
+	return strings.HasPrefix(p.String(), "ok"), nil
+}
+
+func TestBatch(t *testing.T) {
+	d := &existDriver{}
+	var paths []Path
+	for i := 0; i < 50; i++ {
+		paths = append(paths, fakePath{s: fmt.Sprintf("ok%d", i)})
+	}
+	results := BatchExist(context.Background(), d, paths, WithConcurrency(4))
+	for i, v := range results {
+		assert.Equal(t, paths[i], v.Path)
+		assert.True(t, v.Exist)
+	}
+	ok, err := AllExist(results)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	paths = append(paths, fakePath{s: "missing"}, fakePath{s: "broken"})
+	ok, err = AllExist(BatchExist(context.Background(), d, paths))
+	assert.False(t, ok)
+	assert.EqualError(t, err, "broken")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, v := range BatchDelete(ctx, &deleteDriver{}, paths) {
+		assert.NotNil(t, v.Path)
+	}
+}
+
+func TestOpenSeekable(t *testing.T) {
+	s := New()
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	d := &memDriver{fakeDriver{name: "mem"}, map[string]string{"mem:file": content}}
+	assert.NoError(t, s.RegisterDriver(d))
+	ctx := context.Background()
+
+	r, err := s.OpenSeekable(ctx, s.MustParse("file"), WithReadAhead(4))
+	assert.NoError(t, err)
+	want := strings.NewReader(content)
+	for _, v := range []struct {
+		offset int64
+		whence int
+		n      int
+	}{
+		{0, io.SeekStart, 3},
+		{0, io.SeekCurrent, 10},
+		{-5, io.SeekEnd, 10},
+		{2, io.SeekStart, 1},
+		{-1, io.SeekCurrent, 2},
+		{100, io.SeekStart, 1},
+	} {
+		off, err := r.Seek(v.offset, v.whence)
+		assert.NoError(t, err)
+		wantOff, _ := want.Seek(v.offset, v.whence)
+		assert.Equal(t, wantOff, off)
+
+		b := make([]byte, v.n)
+		n, err := io.ReadFull(r, b)
+		wantB := make([]byte, v.n)
+		wantN, wantErr := io.ReadFull(want, wantB)
+		assert.Equal(t, wantErr, err)
+		assert.Equal(t, string(wantB[:wantN]), string(b[:n]))
+	}
+	_, err = r.Seek(-200, io.SeekCurrent)
+	assert.Error(t, err)
+	assert.NoError(t, r.Close())
+	_, err = r.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
@@ -14,16 +14,19 @@ import (
 )
 
 // AggregateToGcs copies a messages from all files into one dest path.
+// The opts are used for the dest writer and its compression.
 func AggregateToGcs(storage filab.FileStorage, ctx context.Context,
-	files []filab.Path, destGsPath filab.Path) error {
+	files []filab.Path, destGsPath filab.Path, opts ...filab.WriteOption) error {
 	var w io.WriteCloser
 	// TODO should not overwrite without checking the size / checksum
-	gceWriter, err := storage.NewWriter(ctx, destGsPath)
+	gceWriter, err := storage.NewAtomicWriter(ctx, destGsPath, opts...)
 	if err != nil {
 		logrus.Errorf("cannot create dest cloud object %s: %s", destGsPath, err)
 		return err
 	}
-	w, err = filab.MaybeAddCompression(destGsPath.String(), gceWriter)
+	// Discards a partial output on errors, no-op after a successful Close.
+	defer gceWriter.Abort()
+	w, err = storage.AddCompression(destGsPath, gceWriter, opts...)
 	if err != nil {
 		logrus.Fatalf("cannot add compression: %s", err)
 		return err
@@ -52,7 +55,9 @@ type Backuper struct {
 	Aggregate         bool
 	DeleteAfterBackup bool
 	StripSrcPrefix    string
-	storage           filab.FileStorage
+	// WriteOptions are used for aggregated files, e.g. a compression level.
+	WriteOptions []filab.WriteOption
+	storage      filab.FileStorage
 
 	queue        []ft
 	inProgress   []ft
@@ -115,7 +120,7 @@ func (b *Backuper) backupAggregated(baseCtx context.Context) error {
 
 		dest := b.GcsPath.Join(mt.t.Format("2006/01/02/150405") + ".pb.gz")
 		ctx, canc := context.WithTimeout(baseCtx, b.CopyTimeout)
-		if err := AggregateToGcs(b.storage, ctx, files, dest); err != nil {
+		if err := AggregateToGcs(b.storage, ctx, files, dest, b.WriteOptions...); err != nil {
 			return err
 		}
 		canc()
@@ -192,6 +197,39 @@ type ft struct {
 	t time.Time
 }
 
+// Watch adds files created or modified below dir until ctx is done.
+// A file already waiting for a backup is not added again.
+func (b *Backuper) Watch(ctx context.Context, dir filab.Path) error {
+	ch, err := b.storage.Watch(ctx, dir)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range ch {
+			if e.Err != nil {
+				logrus.Errorf("watch problem: %s", e.Err)
+				continue
+			}
+			if e.Type == filab.Create || e.Type == filab.Modify {
+				b.addPath(e.Path, e.Info.ModTime)
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *Backuper) addPath(p filab.Path, t time.Time) {
+	b.guardModify.Lock()
+	defer b.guardModify.Unlock()
+	for i, v := range b.queue {
+		if v.f.String() == p.String() {
+			b.queue[i].t = t
+			return
+		}
+	}
+	b.queue = append(b.queue, ft{p, t})
+}
+
 func (b *Backuper) Add(f string, t time.Time) {
 	p, err := b.storage.Parse(f)
 	if err != nil {
@@ -26,7 +26,9 @@ func (c *CloseBuffer) Write(b []byte) (n int, err error) {
 
 func TestAggregate(t *testing.T) {
 	logrus.SetLevel(logrus.DebugLevel)
-	filab.RegisterDriver(local.New())
+	if err := filab.ReplaceDriver(local.New()); err != nil {
+		t.Fatal(err)
+	}
 
 	files := []filab.Path{
 		local.LocalPath("testdata/20170126/143235"),
@@ -2,7 +2,6 @@ package fileutils
 
 import (
 	"compress/gzip"
-	"errors"
 	"io"
 	"os"
 	"strings"
@@ -61,7 +60,7 @@ func (g *CloudStorageClient) ForBucket(bucket string) *CloudStorageClient {
 	return &c
 }
 
-var ErrObjectExist = errors.New("storage: object does exist")
+var ErrObjectExist = filab.ErrExist
 
 func GcsCreateWriter(client *storage.Client, ctx context.Context, gsPath string, overwrite bool) (*storage.Writer, error) {
 	p, err := gcs.ParseGcsPath(gsPath)
@@ -154,26 +153,11 @@ func CopyToCloudF(gclient *CloudStorageClient, filePath, objectPath string) {
 	}
 }
 
-func CopyToCloud(baseCtx context.Context, storage filab.FileStorage,
+// CopyToCloud copies src to dest, on the server side if both paths
+// belong to the same driver.
+func CopyToCloud(ctx context.Context, storage filab.FileStorage,
 	src, dest filab.Path) error {
-
-	ctx, canc := context.WithCancel(baseCtx)
-	defer canc()
-	r, err := storage.NewReader(ctx, src)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-
-	w, err := storage.NewWriter(ctx, dest)
-	if err != nil {
-		return err
-	}
-
-	if _, err := io.Copy(w, r); err != nil {
-		return err
-	}
-	return w.Close()
+	return storage.Copy(ctx, dest, src)
 }
 
 func OldCopyToCloud(gclient *storage.Client, baseCtx context.Context,
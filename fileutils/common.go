@@ -103,17 +103,97 @@ func GenSharded(dirPath filab.Path, prefix string, numShards int, suffix string)
 //}
 //
 
+// ObjectsExist checks files in parallel, it is false if any is missing
+// or cannot be checked.
 func ObjectsExist(storage filab.FileStorage, files ...filab.Path) bool {
+	all := true
+	for _, v := range storage.ExistMany(context.Background(), files) {
+		if v.Err != nil {
+			logrus.Errorf("error checking object existance: %s", v.Err)
+			all = false
+		} else if !v.Exist {
+			logrus.Debugf("not exist: %s", v.Path)
+			all = false
+		}
+	}
+	return all
+}
+
+// WaitExist blocks until all files exist or ctx is done. The directories
+// of files are watched, so they should exist on drivers with native
+// notifications. It fails if a watch stops before ctx is done.
+func WaitExist(ctx context.Context, storage filab.FileStorage, files ...filab.Path) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pending := make(map[string]filab.Path)
+	watched := make(map[string]<-chan filab.Event)
 	for _, v := range files {
-		if ok, err := storage.Exist(context.Background(), v); !ok {
-			logrus.Debugf("not exist: %s", v.String())
-			return false
-		} else if err != nil {
-			logrus.Errorf("error checking object existance: %s", err)
-			return false
+		dir := v.Dir()
+		if _, ok := watched[dir.String()]; !ok {
+			// Watch before checking to not miss a file created in between.
+			ch, err := storage.Watch(ctx, dir)
+			if err != nil {
+				return err
+			}
+			watched[dir.String()] = ch
+		}
+		ok, err := storage.Exist(ctx, v)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			pending[v.String()] = v
 		}
 	}
-	return true
+	events := make(chan filab.Event)
+	stopped := make(chan error, len(watched))
+	for dir, ch := range watched {
+		go func(dir string, ch <-chan filab.Event) {
+			var last error
+			for e := range ch {
+				if e.Err != nil {
+					last = e.Err
+				}
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			err := fmt.Errorf("watch of %s stopped", dir)
+			if last != nil {
+				err = fmt.Errorf("watch of %s stopped: %w", dir, last)
+			}
+			stopped <- err
+		}(dir, ch)
+	}
+	for len(pending) > 0 {
+		select {
+		case e := <-events:
+			if e.Err != nil {
+				// Events may be lost, pending files are checked again.
+				for k, v := range pending {
+					ok, err := storage.Exist(ctx, v)
+					if err != nil {
+						return err
+					}
+					if ok {
+						delete(pending, k)
+					}
+				}
+			} else if e.Type == filab.Create || e.Type == filab.Modify {
+				delete(pending, e.Path.String())
+			}
+		case err := <-stopped:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
 }
 
 // TODO URGENT this is complex and needs tests
@@ -129,7 +209,13 @@ func FindSharded(storage filab.FileStorage, gs filab.Path,
 	var names []filab.Path
 	processed := make(map[string]bool)
 	done := errors.New("done")
-	err := storage.Walk(context.Background(), gs, func(p filab.Path, err error) error {
+	err := storage.Walk(context.Background(), gs, func(p filab.Path, info filab.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir {
+			return nil
+		}
 		if !pattern.MatchString(p.String()) {
 			logrus.Debugf("does not match pattern: %s", p)
 			return nil
@@ -159,9 +245,11 @@ func FindSharded(storage filab.FileStorage, gs filab.Path,
 
 	if err == done {
 		return names, nil
+	} else if err != nil {
+		return nil, &filab.Error{Op: "find sharded", Path: gs, Err: err}
 	}
 
-	return nil, os.ErrNotExist
+	return nil, &filab.Error{Op: "find sharded", Path: gs, Kind: filab.ErrNotExist, Err: os.ErrNotExist}
 }
 
 //
@@ -1,8 +1,14 @@
 package fileutils
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/datainq/filab"
 	"github.com/datainq/filab/local"
@@ -23,4 +29,48 @@ func TestFindSharded(t *testing.T) {
 		storage.MustParse("testdata/sharded/sharded-00002-of-00003.txt"),
 	}
 	assert.Equal(t, expected, fls)
+
+	_, err = FindSharded(storage, storage.MustParse("./testdata/20170126/"), pattern)
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+}
+
+// stoppedWatch is a storage which watches stop immediately.
+type stoppedWatch struct {
+	filab.FileStorage
+}
+
+func (stoppedWatch) Watch(context.Context, filab.Path, ...filab.WatchOption) (<-chan filab.Event, error) {
+	ch := make(chan filab.Event)
+	close(ch)
+	return ch, nil
+}
+
+func TestWaitExist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wait")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	storage := filab.New()
+	storage.RegisterDriver(local.New())
+
+	a := storage.MustParse(filepath.Join(dir, "a"))
+	b := storage.MustParse(filepath.Join(dir, "b"))
+	assert.NoError(t, ioutil.WriteFile(a.String(), nil, 0640))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ioutil.WriteFile(b.String(), nil, 0640)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, WaitExist(ctx, storage, a, b))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = WaitExist(ctx, storage, storage.MustParse(filepath.Join(dir, "c")))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = WaitExist(ctx, stoppedWatch{storage}, storage.MustParse(filepath.Join(dir, "c")))
+	assert.Error(t, err)
+	assert.NoError(t, ctx.Err())
 }
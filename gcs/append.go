@@ -0,0 +1,126 @@
+package gcs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+)
+
+// partPrefix is a prefix of names of temporary part objects of appends,
+// it keeps them out of listings of directories of targets.
+const partPrefix = ".filab-append/"
+
+// maxComponents is the maximum number of components of a composite object.
+const maxComponents = 1024
+
+// appender uploads appended content to a temporary part object and
+// composes it with the target on Close. Each append adds a component to
+// a composite object, a target close to maxComponents is first copied to
+// a single component object, so an appender should still be used for
+// batches of writes, not for single records.
+type appender struct {
+	*writer
+	ctx    context.Context
+	g      *driver
+	target GCSPath
+	part   GCSPath
+	o      filab.WriteOptions
+}
+
+func (g *driver) NewAppender(ctx context.Context, p filab.Path, opts ...filab.WriteOption) (io.WriteCloser, error) {
+	gp := p.(GCSPath)
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	part := gp.WithPath(partPrefix + hex.EncodeToString(b) + "-" + path.Base(gp.Path))
+	w, err := g.NewWriter(ctx, part, filab.IfNotExist())
+	if err != nil {
+		return nil, err
+	}
+	return &appender{
+		writer: w.(*writer),
+		ctx:    ctx,
+		g:      g,
+		target: gp,
+		part:   part,
+		o:      filab.NewWriteOptions(opts...),
+	}, nil
+}
+
+// Close composes the part with the target. The part is deleted whatever
+// the outcome, also if ctx is done.
+func (a *appender) Close() (err error) {
+	defer func() {
+		derr := a.g.Delete(context.WithoutCancel(a.ctx), a.part)
+		if err == nil && !errors.Is(derr, filab.ErrNotExist) {
+			err = derr
+		}
+	}()
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.compose()
+}
+
+// compose replaces the target with the target and the part. The target
+// generation is a precondition, so concurrent appends fail instead of
+// being lost.
+func (a *appender) compose() error {
+	c, err := a.g.getClient("append", a.target)
+	if err != nil {
+		return err
+	}
+	gp := a.target
+	bucket := c.Bucket(gp.Bucket)
+	dst := bucket.Object(gp.Path)
+	srcs := []*storage.ObjectHandle{bucket.Object(a.part.Path)}
+
+	attrs, err := dst.Attrs(a.ctx)
+	switch {
+	case err == storage.ErrObjectNotExist:
+		dst = dst.If(storage.Conditions{DoesNotExist: true})
+		attrs = &storage.ObjectAttrs{
+			ContentType:     a.o.ContentType,
+			ContentEncoding: a.o.ContentEncoding,
+			CacheControl:    a.o.CacheControl,
+			Metadata:        a.o.Metadata,
+		}
+	case err != nil:
+		return wrapErr("append", gp, err)
+	case a.o.IfNotExist:
+		return &filab.Error{Op: "append", Path: gp, Kind: filab.ErrPrecondition}
+	default:
+		if err := a.o.CheckPreconditions(fileInfo(gp, attrs)); err != nil {
+			return &filab.Error{Op: "append", Path: gp, Kind: err}
+		}
+		if flatten(attrs.ComponentCount) {
+			src := bucket.Object(gp.Path).Generation(attrs.Generation)
+			copier := dst.If(storage.Conditions{GenerationMatch: attrs.Generation}).CopierFrom(src)
+			if attrs, err = copier.Run(a.ctx); err != nil {
+				return wrapErr("append", gp, err)
+			}
+		}
+		dst = dst.If(storage.Conditions{GenerationMatch: attrs.Generation})
+		srcs = append([]*storage.ObjectHandle{bucket.Object(gp.Path)}, srcs...)
+	}
+	composer := dst.ComposerFrom(srcs...)
+	composer.ContentType = attrs.ContentType
+	composer.ContentEncoding = attrs.ContentEncoding
+	composer.CacheControl = attrs.CacheControl
+	composer.Metadata = attrs.Metadata
+	_, err = composer.Run(a.ctx)
+	return wrapErr("append", gp, err)
+}
+
+// flatten reports whether a target of the given number of components has to be
+// copied to a single component object before a part is composed with it.
+func flatten(components int64) bool {
+	return components+1 > maxComponents
+}
@@ -0,0 +1,51 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/datainq/filab"
+)
+
+// atomicWriter relies on GCS making an object visible only when an upload
+// finishes. Abort cancels the upload context before closing the writer.
+type atomicWriter struct {
+	io.WriteCloser
+	cancel context.CancelFunc
+	done   bool
+}
+
+func (g *driver) NewAtomicWriter(ctx context.Context, p filab.Path, opts ...filab.WriteOption) (filab.AtomicWriter, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	w, err := g.NewWriter(ctx, p, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &atomicWriter{w, cancel, false}, nil
+}
+
+func (a *atomicWriter) Commit() error {
+	if a.done {
+		return os.ErrClosed
+	}
+	a.done = true
+	defer a.cancel()
+	return a.WriteCloser.Close()
+}
+
+func (a *atomicWriter) Close() error {
+	return a.Commit()
+}
+
+func (a *atomicWriter) Abort() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	a.cancel()
+	// The upload fails with the cancelled context.
+	a.WriteCloser.Close()
+	return nil
+}
@@ -2,7 +2,9 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,7 +15,16 @@ import (
 	"google.golang.org/grpc"
 )
 
-var googleCloudStorage = "Google Cloud Storage Driver"
+// DriverName is a name of the driver in a filab registry.
+const DriverName = "Google Cloud Storage Driver"
+
+var googleCloudStorage = DriverName
+
+// init registers a driver with the default configuration, RegisterDriver
+// of a differently configured one replaces it.
+func init() {
+	filab.RegisterDefaultDriver(New())
+}
 
 type Option interface {
 	apply(*driver)
@@ -25,6 +36,8 @@ func (withBlock) apply(g *driver) {
 	g.connectOnNew = true
 }
 
+// WithBlock makes Init connect to GCS, so connection problems are reported
+// upfront instead of by the first operation.
 func WithBlock() Option {
 	return withBlock{}
 }
@@ -80,6 +93,7 @@ type driver struct {
 	timeout      time.Duration
 	keyFile      string
 	client       *storage.Client
+	ownsClient   bool
 	m            sync.RWMutex
 }
 
@@ -88,11 +102,6 @@ func New(opts ...Option) *driver {
 	for _, o := range opts {
 		o.apply(r)
 	}
-	if r.connectOnNew {
-		if _, err := r.getClient(); err != nil {
-			panic("cannot connect to GCS")
-		}
-	}
 	return r
 }
 
@@ -112,11 +121,31 @@ func (driver) Type() filab.DriverType {
 	return Type()
 }
 
+func (*driver) Capabilities() filab.Capabilities {
+	return filab.Capabilities{
+		Copy:          true,
+		RangeRead:     true,
+		Append:        true,
+		Metadata:      true,
+		Preconditions: true,
+		Versioning:    true,
+	}
+}
+
 func (*driver) Parse(s string) (filab.Path, error) {
 	return ParseGcsPath(s)
 }
 
-func (f *driver) getClient() (*storage.Client, error) {
+// getClient connects lazily, an error is wrapped with op and p.
+func (f *driver) getClient(op string, p filab.Path) (*storage.Client, error) {
+	c, err := f.connect(context.Background())
+	if err != nil {
+		return nil, wrapErr(op, p, err)
+	}
+	return c, nil
+}
+
+func (f *driver) connect(ctx context.Context) (*storage.Client, error) {
 	f.m.RLock()
 	if f.client != nil {
 		f.m.RUnlock()
@@ -124,8 +153,8 @@ func (f *driver) getClient() (*storage.Client, error) {
 	}
 	f.m.RUnlock()
 	f.m.Lock()
+	defer f.m.Unlock()
 	if f.client != nil {
-		f.m.Unlock()
 		return f.client, nil
 	}
 	opts := []option.ClientOption{option.WithGRPCDialOption(grpc.WithBlock())}
@@ -134,16 +163,44 @@ func (f *driver) getClient() (*storage.Client, error) {
 	} else {
 		opts = append(opts, option.WithGRPCDialOption(grpc.WithInsecure()))
 	}
-	var err error
-	ctx, canc := context.WithTimeout(context.Background(), f.timeout)
-	defer canc()
-	f.client, err = storage.NewClient(ctx, opts...)
-	f.m.Unlock()
-	return f.client, err
+	if f.timeout > 0 {
+		var canc context.CancelFunc
+		ctx, canc = context.WithTimeout(ctx, f.timeout)
+		defer canc()
+	}
+	c, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	f.client, f.ownsClient = c, true
+	return c, nil
+}
+
+// Init connects to GCS if the driver was created WithBlock, otherwise
+// the connection is made lazily.
+func (f *driver) Init(ctx context.Context) error {
+	if !f.connectOnNew {
+		return nil
+	}
+	_, err := f.connect(ctx)
+	return err
+}
+
+// Close closes a client created by the driver. A client passed with
+// WithClient is owned by the caller and stays open.
+func (f *driver) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	c := f.client
+	if c == nil || !f.ownsClient {
+		return nil
+	}
+	f.client, f.ownsClient = nil, false
+	return c.Close()
 }
 
 func (g *driver) Exist(ctx context.Context, p filab.Path) (bool, error) {
-	c, err := g.getClient()
+	c, err := g.getClient("exist", p)
 	if err != nil {
 		return false, err
 	}
@@ -152,83 +209,239 @@ func (g *driver) Exist(ctx context.Context, p filab.Path) (bool, error) {
 	if err == storage.ErrObjectNotExist {
 		return false, nil
 	} else if err != nil {
-		return false, err
+		return false, wrapErr("exist", p, err)
 	}
 	return true, nil
 }
 
+func (g *driver) Stat(ctx context.Context, p filab.Path) (filab.FileInfo, error) {
+	c, err := g.getClient("stat", p)
+	if err != nil {
+		return filab.FileInfo{}, err
+	}
+	gp := p.(GCSPath)
+	attrs, err := c.Bucket(gp.Bucket).Object(gp.Path).Attrs(ctx)
+	if err != nil {
+		return filab.FileInfo{}, wrapErr("stat", p, err)
+	}
+	return fileInfo(gp, attrs), nil
+}
+
+func fileInfo(gp GCSPath, attrs *storage.ObjectAttrs) filab.FileInfo {
+	return filab.FileInfo{
+		Path:            gp.WithPath(attrs.Name),
+		Size:            attrs.Size,
+		ModTime:         attrs.Updated,
+		ContentType:     attrs.ContentType,
+		ContentEncoding: attrs.ContentEncoding,
+		MD5:             attrs.MD5,
+		CRC32C:          attrs.CRC32C,
+		Generation:      attrs.Generation,
+	}
+}
+
+func (g *driver) GetMetadata(ctx context.Context, p filab.Path) (filab.Metadata, error) {
+	c, err := g.getClient("getmetadata", p)
+	if err != nil {
+		return filab.Metadata{}, err
+	}
+	gp := p.(GCSPath)
+	attrs, err := c.Bucket(gp.Bucket).Object(gp.Path).Attrs(ctx)
+	if err != nil {
+		return filab.Metadata{}, wrapErr("getmetadata", p, err)
+	}
+	return filab.Metadata{
+		ContentType:     attrs.ContentType,
+		ContentEncoding: attrs.ContentEncoding,
+		CacheControl:    attrs.CacheControl,
+		Custom:          attrs.Metadata,
+	}, nil
+}
+
+func (g *driver) SetMetadata(ctx context.Context, p filab.Path, m filab.Metadata) error {
+	c, err := g.getClient("setmetadata", p)
+	if err != nil {
+		return err
+	}
+	gp := p.(GCSPath)
+	var u storage.ObjectAttrsToUpdate
+	if m.ContentType != "" {
+		u.ContentType = m.ContentType
+	}
+	if m.ContentEncoding != "" {
+		u.ContentEncoding = m.ContentEncoding
+	}
+	if m.CacheControl != "" {
+		u.CacheControl = m.CacheControl
+	}
+	if len(m.Custom) > 0 {
+		// A patch of GCS merges keys.
+		u.Metadata = m.Custom
+	}
+	_, err = c.Bucket(gp.Bucket).Object(gp.Path).Update(ctx, u)
+	return wrapErr("setmetadata", p, err)
+}
+
 func (g *driver) Delete(ctx context.Context, p filab.Path) error {
-	c, err := g.getClient()
+	c, err := g.getClient("delete", p)
 	if err != nil {
 		return err
 	}
 	gp := p.(GCSPath)
-	return c.Bucket(gp.Bucket).Object(gp.Path).Delete(ctx)
+	return wrapErr("delete", p, c.Bucket(gp.Bucket).Object(gp.Path).Delete(ctx))
 }
 
-func (g *driver) NewReader(ctx context.Context, p filab.Path) (io.ReadCloser, error) {
-	c, err := g.getClient()
+// RemoveAll deletes an object and all objects with its path as
+// a directory prefix, listed in one flat listing. Each page of the listing
+// is deleted before the next one is fetched.
+func (g *driver) RemoveAll(ctx context.Context, p filab.Path) error {
+	gs := p.(GCSPath)
+	it, err := g.ListIter(ctx, p, filab.ListOptions{})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	page := make([]filab.Path, 0, filab.DefaultPageSize)
+	if gs.Path != gs.dirPrefix() {
+		page = append(page, gs)
+	}
+	var errs []error
+	flush := func() {
+		if err := filab.DeleteFiles(ctx, g, page); err != nil {
+			errs = append(errs, err)
+		}
+		page = page[:0]
+	}
+	for {
+		f, err := it.Next()
+		if err == filab.Done {
+			break
+		} else if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		page = append(page, f)
+		if len(page) == cap(page) {
+			flush()
+		}
+	}
+	flush()
+	return errors.Join(errs...)
+}
+
+func (g *driver) Copy(ctx context.Context, dst, src filab.Path) error {
+	c, err := g.getClient("copy", src)
+	if err != nil {
+		return err
+	}
+	gs, gd := src.(GCSPath), dst.(GCSPath)
+	srcObj := c.Bucket(gs.Bucket).Object(gs.Path)
+	_, err = c.Bucket(gd.Bucket).Object(gd.Path).CopierFrom(srcObj).Run(ctx)
+	return wrapErr("copy", src, err)
+}
+
+// Rename copies an object on the server side and deletes the source.
+func (g *driver) Rename(ctx context.Context, dst, src filab.Path) error {
+	if err := g.Copy(ctx, dst, src); err != nil {
+		return err
+	}
+	return g.Delete(ctx, src)
+}
+
+func (g *driver) NewReader(ctx context.Context, p filab.Path, _ ...filab.ReadOption) (io.ReadCloser, error) {
+	c, err := g.getClient("open", p)
 	if err != nil {
 		return nil, err
 	}
 	gp := p.(GCSPath)
-	return c.Bucket(gp.Bucket).Object(gp.Path).NewReader(ctx)
+	r, err := c.Bucket(gp.Bucket).Object(gp.Path).NewReader(ctx)
+	if err != nil {
+		return nil, wrapErr("open", p, err)
+	}
+	return reader{r, p}, nil
 }
 
-func (g *driver) NewWriter(ctx context.Context, p filab.Path) (io.WriteCloser, error) {
-	c, err := g.getClient()
+func (g *driver) NewRangeReader(ctx context.Context, p filab.Path, offset, length int64, _ ...filab.ReadOption) (io.ReadCloser, error) {
+	c, err := g.getClient("open", p)
 	if err != nil {
 		return nil, err
 	}
 	gp := p.(GCSPath)
-	return c.Bucket(gp.Bucket).Object(gp.Path).NewWriter(ctx), nil
+	r, err := c.Bucket(gp.Bucket).Object(gp.Path).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, wrapErr("open", p, err)
+	}
+	return reader{r, p}, nil
 }
 
-func (g *driver) List(ctx context.Context, p filab.Path) ([]filab.Path, error) {
-	gs := p.(GCSPath)
-	c, err := g.getClient()
+func (g *driver) NewWriter(ctx context.Context, p filab.Path, opts ...filab.WriteOption) (io.WriteCloser, error) {
+	c, err := g.getClient("create", p)
 	if err != nil {
 		return nil, err
 	}
-	objIter := c.Bucket(gs.Bucket).Objects(ctx, &storage.Query{
-		Prefix: gs.Path,
-	})
-	var ret []filab.Path
-	for {
-		attr, err := objIter.Next()
-		if err != nil {
-			if err == iterator.Done {
-				break
-			}
-			return nil, err
-		}
-		ret = append(ret, gs.WithPath(attr.Name))
+	gp := p.(GCSPath)
+	o := filab.NewWriteOptions(opts...)
+	obj := c.Bucket(gp.Bucket).Object(gp.Path)
+	if obj, err = conditional(ctx, obj, gp, o); err != nil {
+		return nil, err
 	}
-	return ret, nil
+	w := obj.NewWriter(ctx)
+	w.ContentType = o.ContentType
+	w.ContentEncoding = o.ContentEncoding
+	w.CacheControl = o.CacheControl
+	w.Metadata = o.Metadata
+	if o.ChunkSize > 0 {
+		w.ChunkSize = o.ChunkSize
+	}
+	w.CRC32C = o.CRC32C
+	w.SendCRC32C = o.SendCRC32C
+	return newWriter(ctx, obj, w, gp), nil
 }
 
-func (g *driver) Walk(ctx context.Context, p filab.Path, f filab.WalkFunc) error {
+func (g *driver) List(ctx context.Context, p filab.Path) ([]filab.Path, error) {
+	it, err := g.ListIter(ctx, p, filab.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return filab.CollectPaths(it)
+}
+
+func (g *driver) ReadDir(ctx context.Context, p filab.Path) ([]filab.FileInfo, error) {
 	gs := p.(GCSPath)
-	c, err := g.getClient()
+	c, err := g.getClient("readdir", p)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	prefix := gs.dirPrefix()
 	objIter := c.Bucket(gs.Bucket).Objects(ctx, &storage.Query{
-		Prefix: gs.Path,
+		Prefix:    prefix,
+		Delimiter: "/",
 	})
+	var ret []filab.FileInfo
 	for {
 		attr, err := objIter.Next()
 		if err != nil {
 			if err == iterator.Done {
 				break
 			}
-			return err
+			return nil, wrapErr("readdir", p, err)
 		}
-		if err = f(gs.WithPath(attr.Name), nil); err != nil {
-			return err
+		if attr.Prefix != "" {
+			ret = append(ret, filab.FileInfo{
+				Path:  gs.WithPath(strings.TrimSuffix(attr.Prefix, "/")),
+				IsDir: true,
+			})
+		} else if attr.Name != prefix {
+			// An object named like the prefix is a directory placeholder.
+			ret = append(ret, fileInfo(gs, attr))
 		}
 	}
-	return nil
+	filab.SortByName(ret)
+	return ret, nil
+}
+
+func (g *driver) Walk(ctx context.Context, p filab.Path, f filab.WalkFunc) error {
+	return filab.WalkTree(ctx, g, p, f)
 }
 
 //type FileHelper struct {
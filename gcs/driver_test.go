@@ -1,5 +1,59 @@
 package gcs
 
-import "github.com/datainq/filab"
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
 var _ filab.StorageDriver = &driver{}
+
+func TestWrapErr(t *testing.T) {
+	p := MustParseGcs("gs://bucket/file")
+	assert.Nil(t, wrapErr("stat", p, nil))
+	other := errors.New("other")
+	assert.Equal(t, other, wrapErr("stat", p, other))
+
+	err := wrapErr("stat", p, storage.ErrObjectNotExist)
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+	assert.True(t, errors.Is(err, storage.ErrObjectNotExist))
+	assert.Equal(t, "stat gs://bucket/file: storage: object doesn't exist", err.Error())
+
+	err = wrapErr("write", p, &googleapi.Error{Code: http.StatusPreconditionFailed})
+	assert.True(t, errors.Is(err, filab.ErrPrecondition))
+	err = wrapErr("write", p, &googleapi.Error{Code: http.StatusForbidden})
+	assert.True(t, errors.Is(err, filab.ErrPermission))
+	err = wrapErr("write", p, status.Error(codes.AlreadyExists, ""))
+	assert.True(t, errors.Is(err, filab.ErrExist))
+}
+
+func TestWriter_Corrupt(t *testing.T) {
+	h := filab.NewHasher()
+	h.Write([]byte("content"))
+	good := h.Checksums()
+	attrs := &storage.ObjectAttrs{MD5: good.MD5, CRC32C: good.CRC32C}
+
+	w := &writer{h: h}
+	assert.False(t, w.corrupt(attrs))
+	assert.True(t, w.corrupt(&storage.ObjectAttrs{MD5: []byte("other"), CRC32C: good.CRC32C}))
+	assert.True(t, w.corrupt(&storage.ObjectAttrs{CRC32C: good.CRC32C + 1}))
+
+	// GCS verified the upload against a sent CRC32C.
+	w.sent = true
+	assert.False(t, w.corrupt(&storage.ObjectAttrs{CRC32C: good.CRC32C + 1}))
+}
+
+func TestFlatten(t *testing.T) {
+	assert.False(t, flatten(0))
+	assert.False(t, flatten(1))
+	assert.False(t, flatten(maxComponents-1))
+	assert.True(t, flatten(maxComponents))
+	assert.True(t, flatten(maxComponents+1))
+}
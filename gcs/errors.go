@@ -0,0 +1,54 @@
+package gcs
+
+import (
+	"errors"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wrapErr gives a driver-neutral kind to an error returned by the client.
+func wrapErr(op string, p filab.Path, err error) error {
+	if kind := errorKind(err); kind != nil {
+		return &filab.Error{Op: op, Path: p, Kind: kind, Err: err}
+	}
+	return err
+}
+
+func errorKind(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == storage.ErrObjectNotExist || err == storage.ErrBucketNotExist {
+		return filab.ErrNotExist
+	}
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		switch e.Code {
+		case http.StatusNotFound:
+			return filab.ErrNotExist
+		case http.StatusConflict:
+			return filab.ErrExist
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return filab.ErrPermission
+		case http.StatusPreconditionFailed:
+			return filab.ErrPrecondition
+		}
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return filab.ErrNotExist
+	case codes.AlreadyExists:
+		return filab.ErrExist
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return filab.ErrPermission
+	case codes.FailedPrecondition:
+		return filab.ErrPrecondition
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package gcs
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+	"google.golang.org/api/iterator"
+)
+
+// objectIterator fetches objects page by page. Objects are listed in
+// the lexicographic order, so a page token is the name of the last
+// returned object.
+type objectIterator struct {
+	gs    GCSPath
+	pager *iterator.Pager
+	opts  filab.ListOptions
+
+	after    string
+	last     *storage.ObjectAttrs
+	page     []*storage.ObjectAttrs
+	lastPage bool
+	returned int
+	err      error
+}
+
+func (g *driver) ListIter(ctx context.Context, p filab.Path, o filab.ListOptions) (filab.ListIterator, error) {
+	gs := p.(GCSPath)
+	c, err := g.getClient("list", p)
+	if err != nil {
+		return nil, err
+	}
+	q := listQuery(gs, o)
+	if err := q.SetAttrSelection([]string{"Name", "Size", "Updated", "Generation"}); err != nil {
+		return nil, wrapErr("list", p, err)
+	}
+	objIter := c.Bucket(gs.Bucket).Objects(ctx, q)
+	return &objectIterator{
+		gs:    gs,
+		pager: iterator.NewPager(objIter, o.PageSizeOrDefault(), ""),
+		opts:  o,
+		after: q.StartOffset,
+	}, nil
+}
+
+// listQuery lists objects below a directory gs, from the later of
+// StartAfter and a page token. StartOffset is inclusive, Next skips it.
+func listQuery(gs GCSPath, o filab.ListOptions) *storage.Query {
+	prefix := gs.dirPrefix()
+	after := ""
+	if o.StartAfter != "" {
+		after = prefix + o.StartAfter
+	}
+	if o.PageToken > after {
+		after = o.PageToken
+	}
+	return &storage.Query{Prefix: prefix, StartOffset: after}
+}
+
+func (it *objectIterator) Next() (filab.Path, error) {
+	for it.err == nil {
+		if it.opts.MaxResults > 0 && it.returned >= it.opts.MaxResults {
+			it.err = filab.Done
+			break
+		}
+		if len(it.page) == 0 {
+			if it.lastPage {
+				it.err = filab.Done
+				break
+			}
+			token, err := it.pager.NextPage(&it.page)
+			if err != nil {
+				it.err = wrapErr("list", it.gs, err)
+				break
+			}
+			it.lastPage = token == ""
+			continue
+		}
+		attrs := it.page[0]
+		it.page = it.page[1:]
+		// StartOffset is inclusive.
+		if attrs.Name <= it.after {
+			continue
+		}
+		it.after = attrs.Name
+		it.last = attrs
+		it.returned++
+		return it.gs.WithPath(attrs.Name), nil
+	}
+	return nil, it.err
+}
+
+// Info returns size, modification time and generation of the last object,
+// other attributes are not listed.
+func (it *objectIterator) Info() filab.FileInfo {
+	return fileInfo(it.gs, it.last)
+}
+
+func (it *objectIterator) PageToken() string {
+	return it.after
+}
+
+// Close stops the listing, pages are fetched by Next only.
+func (it *objectIterator) Close() error {
+	if it.err == nil {
+		it.err = filab.Done
+	}
+	return nil
+}
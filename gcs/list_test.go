@@ -0,0 +1,21 @@
+package gcs
+
+import (
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQuery(t *testing.T) {
+	p := MustParseGcs("gs://bucket/dir")
+	assert.Equal(t, &storage.Query{Prefix: "dir/"}, listQuery(p, filab.ListOptions{}))
+	assert.Equal(t, &storage.Query{Prefix: "dir/", StartOffset: "dir/b"},
+		listQuery(p, filab.ListOptions{StartAfter: "b"}))
+	assert.Equal(t, &storage.Query{Prefix: "dir/", StartOffset: "dir/c"},
+		listQuery(p, filab.ListOptions{StartAfter: "b", PageToken: "dir/c"}))
+	assert.Equal(t, &storage.Query{Prefix: "dir/", StartOffset: "dir/c"},
+		listQuery(MustParseGcs("gs://bucket/dir/"), filab.ListOptions{StartAfter: "c", PageToken: "dir/a"}))
+	assert.Equal(t, &storage.Query{}, listQuery(MustParseGcs("gs://bucket"), filab.ListOptions{}))
+}
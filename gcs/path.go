@@ -16,6 +16,9 @@ type GCSPath struct {
 }
 
 func (g GCSPath) String() string {
+	if g.Path == "" {
+		return "gs://" + g.Bucket
+	}
 	return fmt.Sprintf("gs://%s/%s", g.Bucket, g.Path)
 }
 
@@ -45,7 +48,20 @@ func (g GCSPath) WithPath(p string) GCSPath {
 }
 
 func (l GCSPath) Dir() filab.Path {
-	return l.WithPath(path.Dir(l.Path))
+	d := path.Dir(l.Path)
+	if d == "." {
+		d = ""
+	}
+	return l.WithPath(d)
+}
+
+// dirPrefix returns a prefix of objects inside the path treated as
+// a directory.
+func (l GCSPath) dirPrefix() string {
+	if l.Path == "" || strings.HasSuffix(l.Path, "/") {
+		return l.Path
+	}
+	return l.Path + "/"
 }
 
 func (l GCSPath) DirStr() string {
@@ -25,3 +25,11 @@ func TestGCSPath_BaseStr(t *testing.T) {
 	assert.Equal(t, "gs://bucket", p.DirStr())
 	assert.Equal(t, "file", p.BaseStr())
 }
+
+func TestGCSPath_Dir(t *testing.T) {
+	p := MustParseGcs("gs://bucket/dir/file")
+	assert.Equal(t, "gs://bucket/dir", p.Dir().String())
+	assert.Equal(t, "gs://bucket", p.Dir().Dir().String())
+	assert.Equal(t, "dir/", p.Dir().(GCSPath).dirPrefix())
+	assert.Equal(t, "", p.Dir().Dir().(GCSPath).dirPrefix())
+}
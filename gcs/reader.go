@@ -0,0 +1,36 @@
+package gcs
+
+import (
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+)
+
+// reader wraps errors of reads and reports the Content-Encoding of an
+// object so the S-methods can decompress it, see filab.ContentEncoder.
+type reader struct {
+	*storage.Reader
+	p filab.Path
+}
+
+func (r reader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if err != nil && err != io.EOF {
+		err = wrapErr("read", r.p, err)
+	}
+	return n, err
+}
+
+func (r reader) WriteTo(w io.Writer) (int64, error) {
+	n, err := r.Reader.WriteTo(w)
+	return n, wrapErr("read", r.p, err)
+}
+
+func (r reader) ContentEncoding() string {
+	// The client decompresses gzip encoded objects itself.
+	if r.Attrs.Decompressed {
+		return ""
+	}
+	return r.Attrs.ContentEncoding
+}
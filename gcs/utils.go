@@ -8,14 +8,13 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ObjectsExist checks files in parallel, it is false if any is missing
+// or cannot be checked.
 func ObjectsExist(client *storage.Client, files ...filab.Path) bool {
-	for _, v := range files {
-		p := v.(GCSPath)
-		_, err := client.Bucket(p.Bucket).Object(p.Path).Attrs(context.TODO())
-		if err == storage.ErrObjectNotExist {
-			logrus.Debugf("not exist: %s", v.String())
-			return false
-		}
+	ok, err := filab.AllExist(filab.BatchExist(context.TODO(), New(WithClient(client)), files))
+	if err != nil {
+		logrus.Errorf("error checking object existance: %s", err)
+		return false
 	}
-	return true
+	return ok
 }
@@ -0,0 +1,94 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/datainq/filab"
+)
+
+// writer wraps errors of an upload. Failed preconditions are reported
+// by GCS when the upload is finished, so usually by Close. An upload with
+// a CRC32C sent upfront is verified by GCS before the object is created.
+// Otherwise checksums of the written content are compared with the ones of
+// the created object and a corrupt object is deleted, but it is visible
+// until then and it stays if the delete fails.
+type writer struct {
+	*storage.Writer
+	ctx  context.Context
+	obj  *storage.ObjectHandle
+	p    GCSPath
+	h    *filab.Hasher
+	sent bool
+}
+
+func newWriter(ctx context.Context, obj *storage.ObjectHandle, w *storage.Writer, p GCSPath) *writer {
+	return &writer{w, ctx, obj, p, filab.NewHasher(), w.SendCRC32C}
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.h.Write(b[:n])
+	return n, w.wrapErr(err)
+}
+
+func (w *writer) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return w.wrapErr(err)
+	}
+	attrs := w.Attrs()
+	if !w.corrupt(attrs) {
+		return nil
+	}
+	// It is deleted unless it was overwritten in the meantime, also if
+	// the write was cancelled.
+	ctx := context.WithoutCancel(w.ctx)
+	err := w.obj.If(storage.Conditions{GenerationMatch: attrs.Generation}).Delete(ctx)
+	if err != nil {
+		err = fmt.Errorf("corrupt object not deleted: %w", err)
+	}
+	return &filab.Error{Op: "write", Path: w.p, Kind: filab.ErrChecksum, Err: err}
+}
+
+// corrupt reports whether a created object differs from the written
+// content. An upload with a sent CRC32C was already verified by GCS.
+func (w *writer) corrupt(attrs *storage.ObjectAttrs) bool {
+	if w.sent {
+		return false
+	}
+	stored := filab.Checksums{MD5: attrs.MD5, CRC32C: attrs.CRC32C}
+	return !w.h.Checksums().Matches(stored)
+}
+
+func (w *writer) wrapErr(err error) error {
+	return wrapErr("write", w.p, err)
+}
+
+// conditional applies write preconditions to an object. GCS has no
+// condition on a modification time, so it is checked upfront and turned
+// into a generation condition.
+func conditional(ctx context.Context, obj *storage.ObjectHandle, gp GCSPath,
+	o filab.WriteOptions) (*storage.ObjectHandle, error) {
+
+	cond := storage.Conditions{
+		DoesNotExist:    o.IfNotExist,
+		GenerationMatch: o.IfGenerationMatch,
+	}
+	if !o.IfModTimeMatch.IsZero() {
+		attrs, err := obj.Attrs(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil, &filab.Error{Op: "create", Path: gp, Kind: filab.ErrPrecondition, Err: err}
+		} else if err != nil {
+			return nil, wrapErr("create", gp, err)
+		}
+		if err := o.CheckPreconditions(fileInfo(gp, attrs)); err != nil {
+			return nil, &filab.Error{Op: "create", Path: gp, Kind: err}
+		}
+		cond.GenerationMatch = attrs.Generation
+	}
+	if cond == (storage.Conditions{}) {
+		return obj, nil
+	}
+	return obj.If(cond), nil
+}
@@ -0,0 +1,277 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns files matching a pattern, sorted by a path. The pattern
+// is matched per a path segment with the syntax of path.Match extended with:
+//   - {a,b} matching any of the comma separated alternatives,
+//   - [!...] as a negated character class,
+//   - ** as a whole segment matching zero or more segments.
+//
+// On drivers with real directories only the tree below the longest literal
+// directory of the pattern is walked. Object stores list all objects with
+// the longest literal prefix of the pattern at once. Directories are never
+// returned.
+func (f *fileStore) Glob(ctx context.Context, pattern string) ([]Path, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ret []Path
+	for _, v := range patterns {
+		paths, err := f.glob(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			if !seen[p.String()] {
+				seen[p.String()] = true
+				ret = append(ret, p)
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].String() < ret[j].String()
+	})
+	return ret, nil
+}
+
+// glob matches a pattern without braces.
+func (f *fileStore) glob(ctx context.Context, pattern string) ([]Path, error) {
+	i := strings.IndexAny(pattern, `*?[\`)
+	if i < 0 {
+		p, err := f.Parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat(ctx, p)
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if info.IsDir {
+			return nil, nil
+		}
+		return []Path{p}, nil
+	}
+	dir := pattern[:strings.LastIndex(pattern[:i], "/")+1]
+	segments := strings.Split(pattern[len(dir):], "/")
+	for k, v := range segments {
+		if _, err := path.Match(matchSyntax(v), ""); err != nil {
+			return nil, fmt.Errorf("filab: glob %q: %w", pattern, err)
+		}
+		segments[k] = matchSyntax(v)
+	}
+	root, err := f.Parse(globRoot(dir))
+	if err != nil {
+		return nil, err
+	}
+	rootStr := root.String()
+	d, err := f.driver(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := d.(DirMaker); !ok && strings.Contains(dir, "://") && !strings.HasSuffix(dir, "://") {
+		// A walk of an object store lists every directory separately.
+		return listGlob(ctx, d, root, segments)
+	}
+
+	var ret []Path
+	err = f.Walk(ctx, root, func(p Path, info FileInfo, err error) error {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		rel, ok := relPath(rootStr, p.String())
+		if !ok {
+			return nil
+		}
+		names := strings.Split(rel, "/")
+		if info.IsDir {
+			if !matchDirSegments(segments, names) {
+				return SkipDir
+			}
+			return nil
+		}
+		if matchSegments(segments, names) {
+			ret = append(ret, p)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// listGlob matches objects of one flat listing of a root directory, a
+// missing bucket has no objects.
+func listGlob(ctx context.Context, d StorageDriver, root Path, segments []string) ([]Path, error) {
+	it, err := d.ListIter(ctx, root, ListOptions{})
+	if errors.Is(err, ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var ret []Path
+	for {
+		p, err := it.Next()
+		if err == Done || errors.Is(err, ErrNotExist) {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		s := p.String()
+		if strings.HasSuffix(s, "/") {
+			// A directory placeholder.
+			continue
+		}
+		if rel, ok := relPath(root.String(), s); ok && matchSegments(segments, strings.Split(rel, "/")) {
+			ret = append(ret, p)
+		}
+	}
+}
+
+// globRoot returns a directory to walk for a literal part of a pattern.
+func globRoot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	if strings.Contains(dir, "://") {
+		if strings.HasSuffix(dir, "://") {
+			return dir
+		}
+		return strings.TrimSuffix(dir, "/")
+	}
+	return path.Clean(dir)
+}
+
+// relPath returns p relative to a root, false for the root itself and paths
+// outside it.
+func relPath(root, p string) (string, bool) {
+	if root == "." {
+		return p, p != "."
+	}
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	if !strings.HasPrefix(p, root) || p == root {
+		return "", false
+	}
+	return p[len(root):], true
+}
+
+// matchSyntax converts a segment to the syntax of path.Match.
+func matchSyntax(segment string) string {
+	return strings.ReplaceAll(segment, "[!", "[^")
+}
+
+// matchSegments reports whether all names match the pattern segments.
+func matchSegments(segments, names []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == "**" {
+			for k := 0; k <= len(names); k++ {
+				if matchSegments(segments[1:], names[k:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segments[0], names[0]); !ok {
+			return false
+		}
+		segments, names = segments[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// matchDirSegments reports whether files below a directory of names may
+// match the pattern segments.
+func matchDirSegments(segments, names []string) bool {
+	for len(names) > 0 {
+		if len(segments) < 2 {
+			// The last segment matches files only.
+			return false
+		}
+		if segments[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(segments[0], names[0]); !ok {
+			return false
+		}
+		segments, names = segments[1:], names[1:]
+	}
+	return true
+}
+
+// expandBraces returns patterns with {a,b} alternatives expanded.
+func expandBraces(pattern string) ([]string, error) {
+	open := -1
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				// A literal brace.
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			var ret []string
+			for _, alt := range splitAlternatives(pattern[open+1 : i]) {
+				expanded, err := expandBraces(pattern[:open] + alt + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, expanded...)
+			}
+			return ret, nil
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("filab: glob %q: unmatched {", pattern)
+	}
+	return []string{pattern}, nil
+}
+
+// splitAlternatives splits s on commas outside of nested braces.
+func splitAlternatives(s string) []string {
+	var ret []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, s[start:])
+}
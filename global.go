@@ -10,6 +10,37 @@ func RegisterDriver(driver StorageDriver) error {
 	return defaultStore.RegisterDriver(driver)
 }
 
+// RegisterDefaultDriver adds a driver to the default FileStorage which
+// RegisterDriver of a driver with the same name, type or scheme replaces.
+// Driver packages use it to register a default configuration on import.
+func RegisterDefaultDriver(driver StorageDriver) error {
+	return defaultStore.(*fileStore).registerDefault(driver)
+}
+
+func ReplaceDriver(driver StorageDriver) error {
+	return defaultStore.ReplaceDriver(driver)
+}
+
+func Driver(name string) (StorageDriver, error) {
+	return defaultStore.Driver(name)
+}
+
+func Drivers() []string {
+	return defaultStore.Drivers()
+}
+
+func CapabilitiesOf(p Path) (Capabilities, error) {
+	return defaultStore.Capabilities(p)
+}
+
+func Init(ctx context.Context) error {
+	return defaultStore.Init(ctx)
+}
+
+func Close() error {
+	return defaultStore.Close()
+}
+
 func Parse(s string) (Path, error) {
 	return defaultStore.Parse(s)
 }
@@ -26,18 +57,98 @@ func Exist(ctx context.Context, p Path) (bool, error) {
 	return defaultStore.Exist(ctx, p)
 }
 
-func NewReader(ctx context.Context, p Path) (io.ReadCloser, error) {
-	return defaultStore.NewReader(ctx, p)
+func Stat(ctx context.Context, p Path) (FileInfo, error) {
+	return defaultStore.Stat(ctx, p)
+}
+
+func NewReader(ctx context.Context, p Path, opts ...ReadOption) (io.ReadCloser, error) {
+	return defaultStore.NewReader(ctx, p, opts...)
+}
+
+func NewRangeReader(ctx context.Context, p Path, offset, length int64, opts ...ReadOption) (io.ReadCloser, error) {
+	return defaultStore.NewRangeReader(ctx, p, offset, length, opts...)
+}
+
+func OpenSeekable(ctx context.Context, p Path, opts ...ReadOption) (io.ReadSeekCloser, error) {
+	return defaultStore.OpenSeekable(ctx, p, opts...)
+}
+
+func NewWriter(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	return defaultStore.NewWriter(ctx, p, opts...)
+}
+
+func NewAtomicWriter(ctx context.Context, p Path, opts ...WriteOption) (AtomicWriter, error) {
+	return defaultStore.NewAtomicWriter(ctx, p, opts...)
+}
+
+func NewAppender(ctx context.Context, p Path, opts ...WriteOption) (io.WriteCloser, error) {
+	return defaultStore.NewAppender(ctx, p, opts...)
+}
+
+func Copy(ctx context.Context, dst, src Path) error {
+	return defaultStore.Copy(ctx, dst, src)
+}
+
+func Rename(ctx context.Context, dst, src Path) error {
+	return defaultStore.Rename(ctx, dst, src)
+}
+
+func GetMetadata(ctx context.Context, p Path) (Metadata, error) {
+	return defaultStore.GetMetadata(ctx, p)
+}
+
+func SetMetadata(ctx context.Context, p Path, m Metadata) error {
+	return defaultStore.SetMetadata(ctx, p, m)
 }
 
-func NewWriter(ctx context.Context, p Path) (io.WriteCloser, error) {
-	return defaultStore.NewWriter(ctx, p)
+func ExistMany(ctx context.Context, paths []Path, opts ...BatchOption) []ExistResult {
+	return defaultStore.ExistMany(ctx, paths, opts...)
+}
+
+func StatMany(ctx context.Context, paths []Path, opts ...BatchOption) []StatResult {
+	return defaultStore.StatMany(ctx, paths, opts...)
+}
+
+func DeleteMany(ctx context.Context, paths []Path, opts ...BatchOption) []DeleteResult {
+	return defaultStore.DeleteMany(ctx, paths, opts...)
+}
+
+func RemoveAll(ctx context.Context, p Path) error {
+	return defaultStore.RemoveAll(ctx, p)
+}
+
+func MkdirAll(ctx context.Context, p Path) error {
+	return defaultStore.MkdirAll(ctx, p)
 }
 
 func List(ctx context.Context, p Path) ([]Path, error) {
 	return defaultStore.List(ctx, p)
 }
 
-func Walk(ctx context.Context, p Path, w WalkFunc) {
-	defaultStore.Walk(ctx, p, w)
+func ListIter(ctx context.Context, p Path, o ListOptions) (ListIterator, error) {
+	return defaultStore.ListIter(ctx, p, o)
+}
+
+func ReadDir(ctx context.Context, p Path) ([]FileInfo, error) {
+	return defaultStore.ReadDir(ctx, p)
+}
+
+func Walk(ctx context.Context, p Path, w WalkFunc) error {
+	return defaultStore.Walk(ctx, p, w)
+}
+
+func Glob(ctx context.Context, pattern string) ([]Path, error) {
+	return defaultStore.Glob(ctx, pattern)
+}
+
+func TempFile(ctx context.Context, dir Path, pattern string, opts ...WriteOption) (*TempWriter, error) {
+	return defaultStore.TempFile(ctx, dir, pattern, opts...)
+}
+
+func TempDir(ctx context.Context, dir Path, pattern string) (*Temp, error) {
+	return defaultStore.TempDir(ctx, dir, pattern)
+}
+
+func Watch(ctx context.Context, p Path, opts ...WatchOption) (<-chan Event, error) {
+	return defaultStore.Watch(ctx, p, opts...)
 }
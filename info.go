@@ -0,0 +1,40 @@
+package filab
+
+import (
+	"sort"
+	"time"
+)
+
+// FileInfo describes a file or an object as reported by a driver.
+// Fields a driver cannot provide are left with zero values.
+type FileInfo struct {
+	Path    Path
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+
+	ContentType string
+	// ContentEncoding is a stored encoding of the content, e.g. gzip.
+	ContentEncoding string
+	// MD5 and CRC32C are checksums of the content if a driver knows them.
+	MD5    []byte
+	CRC32C uint32
+	// Generation identifies a version of the content, it changes with every
+	// write. It can be used with IfGenerationMatch.
+	Generation int64
+}
+
+// Name returns the base name of the file.
+func (i FileInfo) Name() string {
+	if i.Path == nil {
+		return ""
+	}
+	return i.Path.BaseStr()
+}
+
+// SortByName sorts infos by a base name.
+func SortByName(infos []FileInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+}
@@ -0,0 +1,71 @@
+package filab
+
+import "errors"
+
+// Done is returned by ListIterator.Next when there are no more paths.
+var Done = errors.New("no more items in iterator")
+
+// DefaultPageSize is a number of entries fetched at once by a ListIterator
+// if ListOptions.PageSize is not set.
+const DefaultPageSize = 1000
+
+// ListOptions control a listing done by ListIter.
+type ListOptions struct {
+	// PageToken resumes a listing, it must be a value previously returned
+	// by ListIterator.PageToken for the same path.
+	PageToken string
+	// StartAfter limits the listing to entries which names, relative to
+	// the listed path, are lexicographically greater than StartAfter.
+	// It filters entries, it does not order them: object stores list
+	// in the lexicographic order, the local driver in the directory order.
+	StartAfter string
+	// MaxResults limits the number of returned entries, 0 means no limit.
+	MaxResults int
+	// PageSize is a number of entries fetched from a backend at once.
+	PageSize int
+}
+
+func (o ListOptions) PageSizeOrDefault() int {
+	if o.PageSize > 0 {
+		return o.PageSize
+	}
+	return DefaultPageSize
+}
+
+// ListIterator iterates over paths without keeping all of them in memory.
+type ListIterator interface {
+	// Next returns the next path. It returns Done when the listing is
+	// finished or ListOptions.MaxResults entries were returned.
+	Next() (Path, error)
+	// PageToken returns an opaque token which can be used
+	// as ListOptions.PageToken to resume the listing just after the last
+	// path returned by Next.
+	PageToken() string
+	// Close releases resources of an iterator, e.g. an open directory.
+	// Next returns Done after Close. It must be called when a listing is
+	// stopped early, it is a no-op after Next returned an error.
+	Close() error
+}
+
+// InfoIterator is implemented by ListIterators which get file infos with
+// paths, e.g. of object listings.
+type InfoIterator interface {
+	ListIterator
+	// Info returns FileInfo of the path last returned by Next.
+	Info() FileInfo
+}
+
+// CollectPaths reads all remaining paths from an iterator and closes it.
+func CollectPaths(it ListIterator) ([]Path, error) {
+	defer it.Close()
+	var ret []Path
+	for {
+		p, err := it.Next()
+		if err == Done {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+}
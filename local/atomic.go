@@ -0,0 +1,99 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/datainq/filab"
+)
+
+// atomicFile writes to a temporary file in the destination directory and
+// renames it on Commit.
+type atomicFile struct {
+	f    *os.File
+	h    *filab.Hasher
+	d    driver
+	p    filab.Path
+	o    filab.WriteOptions
+	done bool
+}
+
+func (d driver) NewAtomicWriter(_ context.Context, p filab.Path, opts ...filab.WriteOption) (filab.AtomicWriter, error) {
+	if err := d.maybeCreateDir(p); err != nil {
+		return nil, err
+	}
+	o := filab.NewWriteOptions(opts...)
+	if err := d.checkPreconditions(p, o); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p.String()), "."+filepath.Base(p.String())+".tmp")
+	if err != nil {
+		return nil, wrapErr("create", p, err)
+	}
+	return &atomicFile{f: f, h: filab.NewHasher(), d: d, p: p, o: o}, nil
+}
+
+func (a *atomicFile) Write(b []byte) (int, error) {
+	n, err := a.f.Write(b)
+	a.h.Write(b[:n])
+	return n, err
+}
+
+func (a *atomicFile) Commit() error {
+	if a.done {
+		return os.ErrClosed
+	}
+	a.done = true
+	tmp := a.f.Name()
+	err := a.commit(tmp)
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return wrapErr("commit", a.p, err)
+}
+
+func (a *atomicFile) commit(tmp string) error {
+	if err := a.f.Close(); err != nil {
+		return err
+	}
+	mode := a.d.fileMode
+	if a.o.FileMode != 0 {
+		mode = a.o.FileMode
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return err
+	}
+	if err := writeMetadata(tmp, a.o); err != nil {
+		return err
+	}
+	// A rename keeps the modification time the checksums are valid for.
+	storeChecksums(tmp, a.h)
+	if a.o.IfNotExist {
+		// Unlike rename, link fails if the destination exists.
+		if err := os.Link(tmp, a.p.String()); os.IsExist(err) {
+			return &filab.Error{Op: "commit", Path: a.p, Kind: filab.ErrPrecondition, Err: err}
+		} else if err != nil {
+			return err
+		}
+		return os.Remove(tmp)
+	}
+	if err := a.d.checkPreconditions(a.p, a.o); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.p.String())
+}
+
+func (a *atomicFile) Close() error {
+	return a.Commit()
+}
+
+func (a *atomicFile) Abort() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	a.f.Close()
+	return os.Remove(a.f.Name())
+}
@@ -0,0 +1,62 @@
+package local
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/datainq/filab"
+)
+
+// xattrChecksum stores checksums of a file with the size and
+// the modification time they are valid for.
+const xattrChecksum = xattrPrefix + "checksum"
+
+// checksumFile computes checksums of a written file and stores them
+// on Close.
+type checksumFile struct {
+	f *os.File
+	h *filab.Hasher
+}
+
+func (c *checksumFile) Write(b []byte) (int, error) {
+	n, err := c.f.Write(b)
+	c.h.Write(b[:n])
+	return n, err
+}
+
+func (c *checksumFile) Close() error {
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	storeChecksums(c.f.Name(), c.h)
+	return nil
+}
+
+// storeChecksums stores checksums if the file has only the hashed content.
+// Errors are ignored, checksums of such a file are unknown.
+func storeChecksums(file string, h *filab.Hasher) {
+	fi, err := os.Stat(file)
+	if err != nil || fi.Size() != h.Size() {
+		return
+	}
+	c := h.Checksums()
+	setXattrs(file, map[string]string{
+		xattrChecksum: fmt.Sprintf("%d %d %x %08x", fi.Size(), fi.ModTime().UnixNano(), c.MD5, c.CRC32C),
+	})
+}
+
+// loadChecksums sets checksums of info if they are stored and the file
+// did not change since.
+func loadChecksums(info *filab.FileInfo) {
+	v, err := getXattr(info.Path.String(), xattrChecksum)
+	if err != nil {
+		return
+	}
+	var size, mtime int64
+	var c filab.Checksums
+	_, err = fmt.Sscanf(v, "%d %d %x %x", &size, &mtime, &c.MD5, &c.CRC32C)
+	if err != nil || size != info.Size || mtime != info.ModTime.UnixNano() {
+		return
+	}
+	info.MD5, info.CRC32C = c.MD5, c.CRC32C
+}
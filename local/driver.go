@@ -2,11 +2,14 @@ package local
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"mime"
 	"os"
 	"path"
 	"path/filepath"
+	"syscall"
 
 	"github.com/datainq/filab"
 )
@@ -16,8 +19,17 @@ const (
 	DefaultFilePerm = 0640
 )
 
+// DriverName is a name of the driver in a filab registry.
+const DriverName = "local driver"
+
 var localDisk = "local disk"
 
+// init registers a driver with the default configuration, RegisterDriver
+// of a differently configured one replaces it.
+func init() {
+	filab.RegisterDefaultDriver(New())
+}
+
 type Option interface {
 	apply(*driver)
 }
@@ -60,7 +72,7 @@ type driver struct {
 }
 
 func (driver) Name() string {
-	return "local driver"
+	return DriverName
 }
 
 func (driver) Scheme() string {
@@ -75,6 +87,28 @@ func (driver) Type() filab.DriverType {
 	return Type()
 }
 
+// Capabilities do not include Preconditions, only IfNotExist is atomic
+// (O_EXCL or link), a modification time is checked by a Stat before
+// a write.
+func (driver) Capabilities() filab.Capabilities {
+	return filab.Capabilities{
+		Copy:      true,
+		Rename:    true,
+		RangeRead: true,
+		Append:    true,
+		Metadata:  nativeMetadata,
+		Watch:     nativeWatch,
+	}
+}
+
+func (driver) Init(context.Context) error {
+	return nil
+}
+
+func (driver) Close() error {
+	return nil
+}
+
 func (driver) Parse(s string) (filab.Path, error) {
 	return ParseLocalPath(s)
 }
@@ -84,34 +118,193 @@ func (driver) Exist(_ context.Context, p filab.Path) (bool, error) {
 	if os.IsNotExist(err) {
 		return false, nil
 	} else if err != nil {
-		return false, err
+		return false, wrapErr("exist", p, err)
 	}
 	return true, nil
 }
 
+func (driver) Stat(_ context.Context, p filab.Path) (filab.FileInfo, error) {
+	fi, err := os.Stat(p.String())
+	if err != nil {
+		return filab.FileInfo{}, wrapErr("stat", p, err)
+	}
+	info := fileInfo(p, fi)
+	if !info.IsDir {
+		loadChecksums(&info)
+	}
+	return info, nil
+}
+
+func fileInfo(p filab.Path, fi os.FileInfo) filab.FileInfo {
+	info := filab.FileInfo{
+		Path:    p,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+	if !info.IsDir {
+		info.ContentType = mime.TypeByExtension(filepath.Ext(p.String()))
+	}
+	return info
+}
+
 func (driver) Delete(_ context.Context, p filab.Path) error {
-	return os.Remove(p.String())
+	return wrapErr("delete", p, os.Remove(p.String()))
 }
 
-func (driver) NewReader(_ context.Context, p filab.Path) (io.ReadCloser, error) {
-	return os.Open(p.String())
+func (d driver) Copy(_ context.Context, dst, src filab.Path) error {
+	if err := d.maybeCreateDir(dst); err != nil {
+		return err
+	}
+	return wrapErr("copy", src, CopyFile(dst.String(), src.String(), d.fileMode))
 }
 
-func (d driver) NewWriter(_ context.Context, p filab.Path) (io.WriteCloser, error) {
-	if d.createNewDirs {
-		// TODO test it
-		if err := os.MkdirAll(path.Dir(p.String()), d.dirMode); err != nil {
-			return nil, err
+func (d driver) Rename(_ context.Context, dst, src filab.Path) error {
+	if err := d.maybeCreateDir(dst); err != nil {
+		return err
+	}
+	err := os.Rename(src.String(), dst.String())
+	if errors.Is(err, syscall.EXDEV) {
+		// Different file systems, fall back to copy and remove.
+		if err = CopyFile(dst.String(), src.String(), d.fileMode); err != nil {
+			return wrapErr("rename", src, err)
 		}
+		err = os.Remove(src.String())
+	}
+	return wrapErr("rename", src, err)
+}
+
+func (driver) RemoveAll(_ context.Context, p filab.Path) error {
+	return wrapErr("removeall", p, os.RemoveAll(p.String()))
+}
+
+func (d driver) MkdirAll(_ context.Context, p filab.Path) error {
+	return wrapErr("mkdir", p, os.MkdirAll(p.String(), d.dirMode))
+}
+
+func (d driver) maybeCreateDir(p filab.Path) error {
+	if !d.createNewDirs {
+		return nil
+	}
+	return wrapErr("mkdir", p, os.MkdirAll(path.Dir(p.String()), d.dirMode))
+}
+
+func (driver) NewReader(_ context.Context, p filab.Path, _ ...filab.ReadOption) (io.ReadCloser, error) {
+	f, err := os.Open(p.String())
+	if err != nil {
+		return nil, wrapErr("open", p, err)
+	}
+	return f, nil
+}
+
+func (driver) OpenSeekable(_ context.Context, p filab.Path, _ ...filab.ReadOption) (io.ReadSeekCloser, error) {
+	f, err := os.Open(p.String())
+	if err != nil {
+		return nil, wrapErr("open", p, err)
 	}
-	return os.OpenFile(p.String(), os.O_CREATE|os.O_WRONLY, d.fileMode)
+	return f, nil
+}
+
+func (driver) NewRangeReader(_ context.Context, p filab.Path, offset, length int64, _ ...filab.ReadOption) (io.ReadCloser, error) {
+	f, err := os.Open(p.String())
+	if err != nil {
+		return nil, wrapErr("open", p, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, wrapErr("seek", p, err)
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+func (d driver) NewWriter(_ context.Context, p filab.Path, opts ...filab.WriteOption) (io.WriteCloser, error) {
+	// TODO test it
+	if err := d.maybeCreateDir(p); err != nil {
+		return nil, err
+	}
+	o := filab.NewWriteOptions(opts...)
+	if err := d.checkPreconditions(p, o); err != nil {
+		return nil, err
+	}
+	mode := d.fileMode
+	if o.FileMode != 0 {
+		mode = o.FileMode
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if o.IfNotExist {
+		flag |= os.O_EXCL
+	}
+	f, err := os.OpenFile(p.String(), flag, mode)
+	if o.IfNotExist && os.IsExist(err) {
+		return nil, &filab.Error{Op: "create", Path: p, Kind: filab.ErrPrecondition, Err: err}
+	} else if err != nil {
+		return nil, wrapErr("create", p, err)
+	}
+	if err := resetMetadata(p.String(), o); err != nil {
+		f.Close()
+		return nil, wrapErr("create", p, err)
+	}
+	return &checksumFile{f, filab.NewHasher()}, nil
+}
+
+// NewAppender opens a file with O_APPEND, so each write is atomically
+// appended at the end.
+func (d driver) NewAppender(_ context.Context, p filab.Path, opts ...filab.WriteOption) (io.WriteCloser, error) {
+	if err := d.maybeCreateDir(p); err != nil {
+		return nil, err
+	}
+	o := filab.NewWriteOptions(opts...)
+	if err := d.checkPreconditions(p, o); err != nil {
+		return nil, err
+	}
+	mode := d.fileMode
+	if o.FileMode != 0 {
+		mode = o.FileMode
+	}
+	f, err := os.OpenFile(p.String(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+	if err != nil {
+		return nil, wrapErr("append", p, err)
+	}
+	if err := writeMetadata(p.String(), o); err != nil {
+		f.Close()
+		return nil, wrapErr("append", p, err)
+	}
+	return f, nil
+}
+
+// checkPreconditions verifies a modification time precondition. Unlike
+// IfNotExist, it is not atomic with opening a file. Files have no
+// generations.
+func (d driver) checkPreconditions(p filab.Path, o filab.WriteOptions) error {
+	if o.IfGenerationMatch != 0 {
+		return &filab.Error{Op: "create", Path: p, Kind: filab.ErrUnsupported}
+	}
+	if o.IfModTimeMatch.IsZero() {
+		return nil
+	}
+	fi, err := os.Stat(p.String())
+	if os.IsNotExist(err) {
+		return &filab.Error{Op: "create", Path: p, Kind: filab.ErrPrecondition, Err: err}
+	} else if err != nil {
+		return wrapErr("create", p, err)
+	}
+	if err := o.CheckPreconditions(fileInfo(p, fi)); err != nil {
+		return &filab.Error{Op: "create", Path: p, Kind: err}
+	}
+	return nil
 }
 
 func (driver) List(_ context.Context, p filab.Path) ([]filab.Path, error) {
 	var s []filab.Path
 	l, err := ioutil.ReadDir(p.String())
 	if err != nil {
-		return nil, err
+		return nil, wrapErr("list", p, err)
 	}
 	for _, v := range l {
 		s = append(s, p.Join(v.Name()))
@@ -119,12 +312,26 @@ func (driver) List(_ context.Context, p filab.Path) ([]filab.Path, error) {
 	return s, nil
 }
 
+func (driver) ReadDir(_ context.Context, p filab.Path) ([]filab.FileInfo, error) {
+	l, err := ioutil.ReadDir(p.String())
+	if err != nil {
+		return nil, wrapErr("readdir", p, err)
+	}
+	ret := make([]filab.FileInfo, 0, len(l))
+	for _, v := range l {
+		ret = append(ret, fileInfo(p.Join(v.Name()), v))
+	}
+	return ret, nil
+}
+
 func (driver) Walk(_ context.Context, p filab.Path, f filab.WalkFunc) error {
 	return filepath.Walk(p.String(), func(path string, info os.FileInfo, err error) error {
-		if info == nil || info.IsDir() {
-			return nil
+		lp := LocalPath(path)
+		err = wrapErr("walk", lp, err)
+		if info == nil {
+			return f(lp, filab.FileInfo{Path: lp}, err)
 		}
-		return f(LocalPath(path), err)
+		return f(lp, fileInfo(lp, info), err)
 	})
 }
 
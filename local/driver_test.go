@@ -2,8 +2,13 @@ package local
 
 import (
 	"context"
+	"errors"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/datainq/filab"
 	"github.com/stretchr/testify/assert"
@@ -63,3 +68,718 @@ func TestDriver_List(t *testing.T) {
 	assert.Len(t, ps, 1)
 	assert.Equal(t, ps[0].String(), "testdata/file")
 }
+
+func TestDriver_Stat(t *testing.T) {
+	d := New()
+	p, _ := d.Parse("testdata/file")
+	info, err := d.Stat(context.Background(), p)
+	assert.NoError(t, err)
+	assert.Equal(t, p, info.Path)
+	assert.Equal(t, int64(11), info.Size)
+	assert.False(t, info.IsDir)
+	assert.Equal(t, "file", info.Name())
+
+	p, _ = d.Parse("testdata")
+	info, err = d.Stat(context.Background(), p)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir)
+
+	p, _ = d.Parse("testdata/nofile")
+	_, err = d.Stat(context.Background(), p)
+	assert.Error(t, err)
+}
+
+func TestDriver_ListIter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listiter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, n := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, n), nil, 0640))
+	}
+
+	d := New()
+	p, _ := d.Parse(dir)
+	it, err := d.ListIter(context.Background(), p, filab.ListOptions{
+		PageSize: 2, MaxResults: 2})
+	assert.NoError(t, err)
+	first, err := filab.CollectPaths(it)
+	assert.NoError(t, err)
+	assert.Len(t, first, 2)
+
+	it, err = d.ListIter(context.Background(), p, filab.ListOptions{
+		PageToken: it.PageToken(), PageSize: 2})
+	assert.NoError(t, err)
+	rest, err := filab.CollectPaths(it)
+	assert.NoError(t, err)
+	assert.Len(t, rest, 3)
+
+	seen := map[string]bool{}
+	for _, v := range append(first, rest...) {
+		seen[v.BaseStr()] = true
+	}
+	assert.Len(t, seen, 5)
+
+	it, err = d.ListIter(context.Background(), p, filab.ListOptions{StartAfter: "c"})
+	assert.NoError(t, err)
+	after, err := filab.CollectPaths(it)
+	assert.NoError(t, err)
+	assert.Len(t, after, 2)
+
+	// A listing stopped early is closed.
+	it, err = d.ListIter(context.Background(), p, filab.ListOptions{PageSize: 2})
+	assert.NoError(t, err)
+	_, err = it.Next()
+	assert.NoError(t, err)
+	assert.NoError(t, it.Close())
+	_, err = it.Next()
+	assert.Equal(t, filab.Done, err)
+	assert.NoError(t, it.Close())
+}
+
+func TestDriver_ReadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readdir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0740))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file"), []byte("abc"), 0640))
+
+	d := New()
+	p, _ := d.Parse(dir)
+	infos, err := d.ReadDir(context.Background(), p)
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "file", infos[0].Name())
+	assert.False(t, infos[0].IsDir)
+	assert.Equal(t, int64(3), infos[0].Size)
+	assert.Equal(t, "sub", infos[1].Name())
+	assert.True(t, infos[1].IsDir)
+}
+
+func TestDriver_Walk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walk")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, n := range []string{"a/x", "a.txt", "b/skip/y", "b/z", "c"} {
+		f := filepath.Join(dir, n)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0740))
+		assert.NoError(t, ioutil.WriteFile(f, nil, 0640))
+	}
+
+	d := New()
+	p, _ := d.Parse(dir)
+	walk := func(w func(context.Context, filab.Path, filab.WalkFunc) error) []string {
+		var visited []string
+		err := w(context.Background(), p, func(p filab.Path, info filab.FileInfo, err error) error {
+			assert.NoError(t, err)
+			rel, _ := filepath.Rel(dir, p.String())
+			if info.IsDir {
+				rel += "/"
+			}
+			visited = append(visited, rel)
+			if info.Name() == "skip" {
+				return filab.SkipDir
+			}
+			if info.Name() == "c" {
+				return filab.SkipAll
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		return visited
+	}
+	want := []string{"./", "a/", "a/x", "a.txt", "b/", "b/skip/", "b/z", "c"}
+	assert.Equal(t, want, walk(d.Walk))
+	// The generic walk used by object stores must behave the same way.
+	assert.Equal(t, want, walk(func(ctx context.Context, p filab.Path, f filab.WalkFunc) error {
+		return filab.WalkTree(ctx, d, p, f)
+	}))
+
+	p, _ = d.Parse(filepath.Join(dir, "nodir"))
+	var walkErr error
+	err = d.Walk(context.Background(), p, func(_ filab.Path, _ filab.FileInfo, err error) error {
+		walkErr = err
+		return err
+	})
+	assert.Error(t, walkErr)
+	assert.Equal(t, walkErr, err)
+}
+
+func TestDriver_CopyRename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := New(WithNewDir())
+	src := LocalPath(filepath.Join(dir, "src"))
+	assert.NoError(t, ioutil.WriteFile(src.String(), []byte("content"), 0640))
+
+	dst := LocalPath(filepath.Join(dir, "sub", "dst"))
+	assert.NoError(t, d.Copy(context.Background(), dst, src))
+	b, err := ioutil.ReadFile(dst.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+
+	moved := LocalPath(filepath.Join(dir, "moved"))
+	assert.NoError(t, d.Rename(context.Background(), moved, src))
+	ok, err := d.Exist(context.Background(), src)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	b, err = ioutil.ReadFile(moved.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+}
+
+func TestDriver_NewRangeReader(t *testing.T) {
+	d := New()
+	p, _ := d.Parse("testdata/file")
+	r, err := d.NewRangeReader(context.Background(), p, 5, 3)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, b, 3)
+	assert.NoError(t, r.Close())
+
+	r, err = d.NewRangeReader(context.Background(), p, 5, -1)
+	assert.NoError(t, err)
+	b, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, b, 6)
+	assert.NoError(t, r.Close())
+
+	ra := filab.NewReaderAt(context.Background(), d, p)
+	b = make([]byte, 4)
+	n, err := ra.ReadAt(b, 9)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestDriver_NewWriterOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	p := LocalPath(filepath.Join(dir, "raw.gz"))
+	w, err := s.NewWriterS(p, filab.WithFileMode(0600),
+		filab.WithCompression(filab.NoCompression))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("not compressed"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	fi, err := os.Stat(p.String())
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	b, err := ioutil.ReadFile(p.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "not compressed", string(b))
+
+	_, err = s.NewReaderS(p)
+	assert.Error(t, err)
+	r, err := s.NewReaderS(p, filab.WithCompression(filab.NoCompression))
+	assert.NoError(t, err)
+	b, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "not compressed", string(b))
+	assert.NoError(t, r.Close())
+}
+
+func TestDriver_NewWriterSInvalidCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codec")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	p := LocalPath(filepath.Join(dir, "file.bz2"))
+	assert.NoError(t, ioutil.WriteFile(p.String(), []byte("content"), 0644))
+
+	// bzip2 cannot write, the existing file is not truncated.
+	_, err = s.NewWriterS(p)
+	assert.True(t, errors.Is(err, filab.ErrUnsupported))
+	_, err = s.NewWriterS(p, filab.WithCompression("gzip"), filab.WithCompressionLevel(42))
+	assert.Error(t, err)
+	b, err := ioutil.ReadFile(p.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+}
+
+func TestDriver_NewWriterPreconditions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preconditions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := New()
+	ctx := context.Background()
+	p := LocalPath(filepath.Join(dir, "file"))
+	w, err := d.NewWriter(ctx, p, filab.IfNotExist())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	_, err = d.NewWriter(ctx, p, filab.IfNotExist())
+	assert.True(t, errors.Is(err, filab.ErrPrecondition))
+
+	info, err := d.Stat(ctx, p)
+	assert.NoError(t, err)
+	assert.Zero(t, info.Generation)
+	_, err = d.NewWriter(ctx, p, filab.IfGenerationMatch(1))
+	assert.True(t, errors.Is(err, filab.ErrUnsupported))
+	_, err = d.NewWriter(ctx, p, filab.IfModTimeMatch(info.ModTime.Add(time.Second)))
+	assert.True(t, errors.Is(err, filab.ErrPrecondition))
+
+	w, err = d.NewWriter(ctx, p, filab.IfModTimeMatch(info.ModTime))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	// A FileStorage checks the generation with the capabilities.
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(d))
+	_, err = s.NewWriter(ctx, p, filab.IfGenerationMatch(1))
+	assert.True(t, errors.Is(err, filab.ErrUnsupported))
+}
+
+func TestDriver_NewAtomicWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := New()
+	ctx := context.Background()
+	p := LocalPath(filepath.Join(dir, "file"))
+	w, err := d.NewAtomicWriter(ctx, p)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("partial"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Abort())
+	names, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, names, 0)
+
+	w, err = d.NewAtomicWriter(ctx, p, filab.IfNotExist())
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("content"))
+	assert.NoError(t, err)
+	ok, _ := d.Exist(ctx, p)
+	assert.False(t, ok)
+	assert.NoError(t, w.Commit())
+	assert.NoError(t, w.Abort())
+	b, err := ioutil.ReadFile(p.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+
+	w, err = d.NewAtomicWriter(ctx, p, filab.IfNotExist())
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(w.Close(), filab.ErrPrecondition))
+	names, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, names, 1)
+}
+
+func TestDriver_Errors(t *testing.T) {
+	d := New()
+	ctx := context.Background()
+	p, _ := d.Parse("testdata/nofile")
+	_, err := d.Stat(ctx, p)
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = d.NewReader(ctx, p)
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+	assert.True(t, errors.Is(d.Delete(ctx, p), filab.ErrNotExist))
+	_, err = d.ReadDir(ctx, p)
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+}
+
+func TestDriver_Glob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, n := range []string{"2024/01/a.pb.gz", "2024/02/b.pb.gz", "2024/02/c.txt",
+		"2024/x.pb.gz", "2025/01/d.pb.gz", "2025/01/deep/e.pb.gz"} {
+		f := filepath.Join(dir, n)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0740))
+		assert.NoError(t, ioutil.WriteFile(f, nil, 0640))
+	}
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	glob := func(pattern string) []string {
+		paths, err := s.Glob(context.Background(), filepath.Join(dir, pattern))
+		assert.NoError(t, err)
+		var ret []string
+		for _, p := range paths {
+			rel, _ := filepath.Rel(dir, p.String())
+			ret = append(ret, rel)
+		}
+		return ret
+	}
+	assert.Equal(t, []string{"2024/01/a.pb.gz", "2024/02/b.pb.gz"}, glob("2024/*/*.pb.gz"))
+	assert.Equal(t, []string{"2024/01/a.pb.gz", "2025/01/d.pb.gz"}, glob("{2024,2025}/0[!2]/?.pb.gz"))
+	assert.Equal(t, []string{"2024/01/a.pb.gz", "2024/02/b.pb.gz", "2024/x.pb.gz",
+		"2025/01/d.pb.gz", "2025/01/deep/e.pb.gz"}, glob("**/*.pb.gz"))
+	assert.Equal(t, []string{"2024/02/c.txt"}, glob("2024/02/c.txt"))
+	assert.Empty(t, glob("2024/*/c.pb"))
+	assert.Empty(t, glob("2026/*"))
+}
+
+func TestDriver_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "old"), nil, 0640))
+	out, err := ioutil.TempDir("", "watch-out")
+	assert.NoError(t, err)
+	defer os.RemoveAll(out)
+
+	d := New()
+	p, _ := d.Parse(dir)
+	watch := func(w func(context.Context, filab.Path, ...filab.WatchOption) (<-chan filab.Event, error)) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ch, err := w(ctx, p, filab.WithPollInterval(10*time.Millisecond))
+		if !assert.NoError(t, err) {
+			return
+		}
+		sub := filepath.Join(dir, "sub")
+		assert.NoError(t, os.Mkdir(sub, 0740))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(sub, "new"), []byte("a"), 0640))
+		assert.NoError(t, os.Remove(filepath.Join(dir, "old")))
+
+		got := make(map[string]filab.EventType)
+		for len(got) < 2 {
+			e, ok := <-ch
+			if !assert.True(t, ok, "watch ended") {
+				return
+			}
+			assert.NoError(t, e.Err)
+			rel, _ := filepath.Rel(dir, e.Path.String())
+			if _, ok := got[rel]; !ok {
+				got[rel] = e.Type
+			}
+		}
+		assert.Equal(t, map[string]filab.EventType{
+			"sub/new": filab.Create,
+			"old":     filab.Delete,
+		}, got)
+
+		// Files of a directory moved out are deleted, later changes of
+		// them are not reported.
+		moved := filepath.Join(out, "sub")
+		assert.NoError(t, os.Rename(sub, moved))
+		var deleted filab.Event
+		for e := range ch {
+			assert.NoError(t, e.Err)
+			if e.Type == filab.Delete {
+				deleted = e
+				break
+			}
+		}
+		assert.Equal(t, filab.Path(LocalPath(filepath.Join(sub, "new"))), deleted.Path)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(moved, "other"), nil, 0640))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "marker"), nil, 0640))
+		for e := range ch {
+			assert.NoError(t, e.Err)
+			if e.Path.String() == filepath.Join(dir, "marker") {
+				break
+			}
+			assert.Equal(t, filepath.Join(sub, "new"), e.Path.String(), "unexpected event")
+		}
+		cancel()
+		for range ch {
+		}
+
+		// Restore the initial state.
+		assert.NoError(t, os.RemoveAll(moved))
+		assert.NoError(t, os.Remove(filepath.Join(dir, "marker")))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "old"), nil, 0640))
+	}
+	watch(d.Watch)
+	watch(func(ctx context.Context, p filab.Path, opts ...filab.WatchOption) (<-chan filab.Event, error) {
+		return filab.PollWatch(ctx, d, p, opts...)
+	})
+
+	// A file is polled if a native watch cannot watch it.
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(d))
+	file := LocalPath(filepath.Join(dir, "old"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch, err := s.Watch(ctx, file, filab.WithPollInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(file.String(), []byte("changed"), 0640))
+	e := <-ch
+	assert.Equal(t, filab.Modify, e.Type)
+	assert.Equal(t, file.String(), e.Path.String())
+}
+
+func TestDriver_RemoveAllMkdirAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "removeall")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	ctx := context.Background()
+	p := LocalPath(filepath.Join(dir, "a/b/c"))
+	assert.NoError(t, s.MkdirAll(ctx, p))
+	assert.NoError(t, s.MkdirAll(ctx, p))
+	for _, n := range []string{"a/x", "a/b/y", "a/b/c/z"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, n), nil, 0640))
+	}
+
+	// The generic removal used by object stores deletes files only.
+	assert.NoError(t, filab.RemoveTree(ctx, New(), LocalPath(filepath.Join(dir, "a/b"))))
+	info, err := s.Stat(ctx, p)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir)
+	ok, _ := s.Exist(ctx, LocalPath(filepath.Join(dir, "a/b/c/z")))
+	assert.False(t, ok)
+	ok, _ = s.Exist(ctx, LocalPath(filepath.Join(dir, "a/x")))
+	assert.True(t, ok)
+
+	assert.NoError(t, s.RemoveAll(ctx, LocalPath(filepath.Join(dir, "a"))))
+	ok, _ = s.Exist(ctx, LocalPath(filepath.Join(dir, "a")))
+	assert.False(t, ok)
+	assert.NoError(t, s.RemoveAll(ctx, LocalPath(filepath.Join(dir, "a"))))
+	assert.NoError(t, filab.RemoveTree(ctx, New(), LocalPath(filepath.Join(dir, "a"))))
+}
+
+func TestDriver_Metadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	ctx := context.Background()
+	p := LocalPath(filepath.Join(dir, "file.pb"))
+	w, err := s.NewWriter(ctx, p, filab.WithContentType("application/x-protobuf"),
+		filab.WithCacheControl("no-cache"),
+		filab.WithMetadata(map[string]string{"producer": "test"}))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	m, err := s.GetMetadata(ctx, p)
+	if errors.Is(err, filab.ErrUnsupported) {
+		t.Skip("no extended attributes")
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, filab.Metadata{
+		ContentType:  "application/x-protobuf",
+		CacheControl: "no-cache",
+		Custom:       map[string]string{"producer": "test"},
+	}, m)
+
+	assert.NoError(t, s.SetMetadata(ctx, p, filab.Metadata{
+		ContentEncoding: "gzip",
+		Custom:          map[string]string{"schema": "2"},
+	}))
+	m, err = s.GetMetadata(ctx, p)
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", m.ContentEncoding)
+	assert.Equal(t, "application/x-protobuf", m.ContentType)
+	assert.Equal(t, map[string]string{"producer": "test", "schema": "2"}, m.Custom)
+
+	// An overwrite replaces all metadata.
+	w, err = s.NewWriter(ctx, p, filab.WithMetadata(map[string]string{"schema": "3"}))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	m, err = s.GetMetadata(ctx, p)
+	assert.NoError(t, err)
+	assert.Equal(t, filab.Metadata{Custom: map[string]string{"schema": "3"}}, m)
+
+	_, err = s.GetMetadata(ctx, LocalPath(filepath.Join(dir, "missing")))
+	assert.True(t, errors.Is(err, filab.ErrNotExist))
+}
+
+func TestDriver_Checksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksums")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	ctx := context.Background()
+	content := []byte("some content")
+	h := filab.NewHasher()
+	h.Write(content)
+	want := h.Checksums()
+
+	for _, n := range []string{"plain", "atomic"} {
+		p := LocalPath(filepath.Join(dir, n))
+		var w io.WriteCloser
+		if n == "atomic" {
+			w, err = s.NewAtomicWriter(ctx, p)
+		} else {
+			w, err = s.NewWriter(ctx, p)
+		}
+		assert.NoError(t, err)
+		_, err = w.Write(content)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		info, err := s.Stat(ctx, p)
+		assert.NoError(t, err)
+		if filab.ChecksumsOf(info).IsZero() {
+			t.Skip("no extended attributes")
+		}
+		assert.Equal(t, want, filab.ChecksumsOf(info), n)
+
+		r, err := s.NewReader(ctx, p, filab.VerifyChecksum())
+		assert.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+	}
+
+	// Corrupt a file keeping its size and modification time.
+	p := LocalPath(filepath.Join(dir, "plain"))
+	fi, err := os.Stat(p.String())
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(p.String(), []byte("SOME content"), 0640))
+	assert.NoError(t, os.Chtimes(p.String(), fi.ModTime(), fi.ModTime()))
+	r, err := s.NewReader(ctx, p, filab.VerifyChecksum())
+	assert.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(r.Close(), filab.ErrChecksum))
+
+	// A changed file has unknown checksums.
+	assert.NoError(t, ioutil.WriteFile(p.String(), []byte("other"), 0640))
+	info, err := s.Stat(ctx, p)
+	assert.NoError(t, err)
+	assert.True(t, filab.ChecksumsOf(info).IsZero())
+	_, err = s.NewReader(ctx, p, filab.VerifyChecksum())
+	assert.True(t, errors.Is(err, filab.ErrUnsupported))
+}
+
+// rewriteDriver hides native appends of the driver.
+type rewriteDriver struct {
+	filab.StorageDriver
+}
+
+func TestDriver_NewAppender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "append")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	native := filab.New()
+	assert.NoError(t, native.RegisterDriver(New()))
+	emulated := filab.New()
+	assert.NoError(t, emulated.RegisterDriver(rewriteDriver{New()}))
+	for name, s := range map[string]filab.FileStorage{"native": native, "emulated": emulated} {
+		p := LocalPath(filepath.Join(dir, name))
+		for _, v := range []string{"a", "bc", "def"} {
+			w, err := s.NewAppender(ctx, p)
+			assert.NoError(t, err, name)
+			_, err = io.WriteString(w, v)
+			assert.NoError(t, err, name)
+			assert.NoError(t, w.Close(), name)
+		}
+		b, err := ioutil.ReadFile(p.String())
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdef", string(b), name)
+
+		// A writer replaces the whole content.
+		w, err := s.NewWriter(ctx, p)
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, "x")
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		b, err = ioutil.ReadFile(p.String())
+		assert.NoError(t, err)
+		assert.Equal(t, "x", string(b), name)
+	}
+}
+
+func TestDriver_Temp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "temp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	root := LocalPath(dir)
+
+	w, err := s.TempFile(ctx, root, "part-*.pb")
+	assert.NoError(t, err)
+	assert.Regexp(t, `/part-[0-9a-f]{16}\.pb$`, w.Path.String())
+	_, err = io.WriteString(w, "content")
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	ok, _ := s.Exist(ctx, w.Path)
+	assert.True(t, ok)
+	assert.NoError(t, w.Remove())
+	assert.NoError(t, w.Remove())
+	ok, _ = s.Exist(ctx, w.Path)
+	assert.False(t, ok)
+
+	d, err := s.TempDir(ctx, root, "stage")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(d.Path.String(), "x"), nil, 0640))
+	w, err = s.TempFile(ctx, d.Path, "")
+	assert.NoError(t, err)
+
+	// Both are removed with the context.
+	cancel()
+	for i := 0; i < 100; i++ {
+		if ok, _ = s.Exist(context.Background(), d.Path); !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(t, ok)
+	assert.NoError(t, d.Remove())
+	entries, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Cancellation while writing aborts the writer.
+	ctx, cancel = context.WithCancel(context.Background())
+	w, err = s.TempFile(ctx, root, "")
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		for {
+			if _, err := io.WriteString(w, "content"); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	cancel()
+	assert.True(t, errors.Is(<-done, os.ErrClosed))
+	assert.NoError(t, w.Remove())
+	assert.True(t, errors.Is(w.Commit(), os.ErrClosed))
+	entries, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDriver_OpenSeekable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seekable")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := LocalPath(filepath.Join(dir, "file"))
+	assert.NoError(t, ioutil.WriteFile(p.String(), []byte("0123456789"), 0640))
+
+	s := filab.New()
+	assert.NoError(t, s.RegisterDriver(New()))
+	native, err := s.OpenSeekable(context.Background(), p)
+	assert.NoError(t, err)
+	// The reader used by object stores must behave the same way.
+	ranged, err := filab.NewSeekableReader(context.Background(), New(), p, filab.WithReadAhead(2))
+	assert.NoError(t, err)
+	for name, r := range map[string]io.ReadSeekCloser{"native": native, "range": ranged} {
+		off, err := r.Seek(-3, io.SeekEnd)
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(7), off, name)
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err, name)
+		assert.Equal(t, "789", string(b), name)
+		assert.NoError(t, r.Close(), name)
+	}
+}
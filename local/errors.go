@@ -0,0 +1,28 @@
+package local
+
+import (
+	"errors"
+	"os"
+
+	"github.com/datainq/filab"
+)
+
+// wrapErr gives a driver-neutral kind to an error returned by os.
+func wrapErr(op string, p filab.Path, err error) error {
+	var kind error
+	switch {
+	case err == nil:
+		return nil
+	case os.IsNotExist(err):
+		kind = filab.ErrNotExist
+	case os.IsExist(err):
+		kind = filab.ErrExist
+	case os.IsPermission(err):
+		kind = filab.ErrPermission
+	case errors.Is(err, errors.ErrUnsupported):
+		kind = filab.ErrUnsupported
+	default:
+		return err
+	}
+	return &filab.Error{Op: op, Path: p, Kind: kind, Err: err}
+}
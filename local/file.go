@@ -2,8 +2,6 @@ package local
 
 import (
 	"archive/zip"
-	"compress/gzip"
-	"compress/zlib"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -53,12 +51,7 @@ func NewFileReader(file string) (f io.ReadCloser, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if strings.HasSuffix(file, ".7z") {
-		f, err = zlib.NewReader(f)
-	} else if strings.HasSuffix(file, ".gz") {
-		f, err = gzip.NewReader(f)
-	}
-	return f, err
+	return filab.MaybeAddDecompression(file, f)
 }
 
 func NewFileWriter(file string) (w io.WriteCloser, err error) {
@@ -66,7 +59,12 @@ func NewFileWriter(file string) (w io.WriteCloser, err error) {
 	if err != nil {
 		return nil, err
 	}
-	return filab.MaybeAddCompression(file, w)
+	w1, err := filab.MaybeAddCompression(file, w)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w1, nil
 }
 
 // CopyFile copies the contents from src to dst atomically.
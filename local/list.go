@@ -0,0 +1,103 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/datainq/filab"
+)
+
+// dirIterator reads a directory in chunks. The entries are returned in
+// the directory order, not sorted like on object stores, because a page
+// token is the number of entries consumed from the directory stream.
+type dirIterator struct {
+	dir  filab.Path
+	f    *os.File
+	opts filab.ListOptions
+
+	names    []string
+	consumed int
+	returned int
+	err      error
+}
+
+func (driver) ListIter(_ context.Context, p filab.Path, o filab.ListOptions) (filab.ListIterator, error) {
+	skip := 0
+	if o.PageToken != "" {
+		var err error
+		if skip, err = strconv.Atoi(o.PageToken); err != nil || skip < 0 {
+			return nil, fmt.Errorf("invalid page token: %q", o.PageToken)
+		}
+	}
+	f, err := os.Open(p.String())
+	if err != nil {
+		return nil, wrapErr("list", p, err)
+	}
+	it := &dirIterator{dir: p, f: f, opts: o}
+	for it.consumed < skip {
+		n := skip - it.consumed
+		if n > o.PageSizeOrDefault() {
+			n = o.PageSizeOrDefault()
+		}
+		names, err := f.Readdirnames(n)
+		it.consumed += len(names)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			f.Close()
+			return nil, wrapErr("list", p, err)
+		}
+	}
+	return it, nil
+}
+
+func (it *dirIterator) Next() (filab.Path, error) {
+	for it.err == nil {
+		if it.opts.MaxResults > 0 && it.returned >= it.opts.MaxResults {
+			it.close(filab.Done)
+			break
+		}
+		if len(it.names) == 0 {
+			names, err := it.f.Readdirnames(it.opts.PageSizeOrDefault())
+			if err == io.EOF {
+				it.close(filab.Done)
+				break
+			} else if err != nil {
+				it.close(wrapErr("list", it.dir, err))
+				break
+			}
+			it.names = names
+			continue
+		}
+		name := it.names[0]
+		it.names = it.names[1:]
+		it.consumed++
+		if name <= it.opts.StartAfter {
+			continue
+		}
+		it.returned++
+		return it.dir.Join(name), nil
+	}
+	return nil, it.err
+}
+
+func (it *dirIterator) PageToken() string {
+	return strconv.Itoa(it.consumed)
+}
+
+// Close closes the directory unless Next already did.
+func (it *dirIterator) Close() error {
+	if it.err != nil {
+		return nil
+	}
+	it.err = filab.Done
+	return it.f.Close()
+}
+
+func (it *dirIterator) close(err error) {
+	it.err = err
+	it.f.Close()
+}
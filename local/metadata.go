@@ -0,0 +1,89 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/datainq/filab"
+)
+
+// Metadata are stored in extended attributes of files with the prefix.
+const (
+	xattrPrefix       = "user.filab."
+	xattrCustomPrefix = xattrPrefix + "meta."
+)
+
+func (driver) GetMetadata(_ context.Context, p filab.Path) (filab.Metadata, error) {
+	attrs, err := getXattrs(p.String())
+	if err != nil {
+		return filab.Metadata{}, wrapErr("getmetadata", p, err)
+	}
+	m := filab.Metadata{
+		ContentType:     attrs[xattrPrefix+"content-type"],
+		ContentEncoding: attrs[xattrPrefix+"content-encoding"],
+		CacheControl:    attrs[xattrPrefix+"cache-control"],
+	}
+	for k, v := range attrs {
+		if strings.HasPrefix(k, xattrCustomPrefix) {
+			if m.Custom == nil {
+				m.Custom = make(map[string]string)
+			}
+			m.Custom[strings.TrimPrefix(k, xattrCustomPrefix)] = v
+		}
+	}
+	if m.ContentType == "" {
+		m.ContentType = mime.TypeByExtension(filepath.Ext(p.String()))
+	}
+	return m, nil
+}
+
+func (driver) SetMetadata(_ context.Context, p filab.Path, m filab.Metadata) error {
+	return wrapErr("setmetadata", p, setMetadata(p.String(), m))
+}
+
+// writeMetadata stores metadata of write options. File systems without
+// extended attributes ignore them, like drivers ignore other options they
+// cannot interpret.
+func writeMetadata(file string, o filab.WriteOptions) error {
+	err := setMetadata(file, o.WriteMetadata())
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// resetMetadata replaces metadata of an overwritten file with the ones
+// of write options.
+func resetMetadata(file string, o filab.WriteOptions) error {
+	err := removeXattrs(file)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return writeMetadata(file, o)
+}
+
+// setMetadata stores non-empty fields of m in extended attributes.
+func setMetadata(file string, m filab.Metadata) error {
+	if m.IsZero() {
+		return nil
+	}
+	attrs := make(map[string]string)
+	for k, v := range map[string]string{
+		"content-type":     m.ContentType,
+		"content-encoding": m.ContentEncoding,
+		"cache-control":    m.CacheControl,
+	} {
+		if v != "" {
+			attrs[xattrPrefix+k] = v
+		}
+	}
+	for k, v := range m.Custom {
+		attrs[xattrCustomPrefix+k] = v
+	}
+	return setXattrs(file, attrs)
+}
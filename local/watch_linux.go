@@ -0,0 +1,224 @@
+//go:build linux
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/datainq/filab"
+	"golang.org/x/sys/unix"
+)
+
+// nativeWatch reports that Watch uses inotify.
+const nativeWatch = true
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+// inotify watches a tree of directories, each has its own watch descriptor.
+// Files of the tree are tracked, so a directory moved out of it emits
+// Delete events for them.
+type inotify struct {
+	f     *os.File
+	fd    int
+	dirs  map[int]string
+	files map[string]bool
+	ch    chan filab.Event
+}
+
+// Watch emits inotify events of files below a directory p. A created file
+// is usually followed by a Modify event when it is closed after writing.
+// A file p is not supported, FileStorage polls it instead.
+func (d driver) Watch(ctx context.Context, p filab.Path, _ ...filab.WatchOption) (<-chan filab.Event, error) {
+	fi, err := os.Stat(p.String())
+	if err != nil {
+		return nil, wrapErr("watch", p, err)
+	}
+	if !fi.IsDir() {
+		return nil, &filab.Error{Op: "watch", Path: p, Kind: filab.ErrUnsupported,
+			Err: errors.New("not a directory")}
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, wrapErr("watch", p, err)
+	}
+	w := &inotify{
+		// A non-blocking descriptor is served by the runtime poller, so
+		// Close unblocks a pending Read.
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		fd:    fd,
+		dirs:  make(map[int]string),
+		files: make(map[string]bool),
+		ch:    make(chan filab.Event),
+	}
+	if err := w.addTree(p.String(), nil); err != nil {
+		w.f.Close()
+		return nil, wrapErr("watch", p, err)
+	}
+	go func() {
+		<-ctx.Done()
+		w.f.Close()
+	}()
+	go w.run(ctx)
+	return w.ch, nil
+}
+
+// addTree watches a directory and its subdirectories. Files found in them
+// are passed to created, it handles directories created or moved in
+// after the watch started.
+func (w *inotify) addTree(root string, created func(string, os.FileInfo)) error {
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if p != root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			w.files[p] = true
+			if created != nil {
+				created(p, fi)
+			}
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, p, watchMask)
+		if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: p, Err: err}
+		}
+		w.dirs[wd] = p
+		return nil
+	})
+}
+
+// removeTree stops watching a directory moved out of the tree and returns
+// Delete events of its files.
+func (w *inotify) removeTree(events []filab.Event, root string) []filab.Event {
+	prefix := root + string(filepath.Separator)
+	for wd, dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			// The watch is gone if the directory was removed meanwhile.
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+	var files []string
+	for p := range w.files {
+		if strings.HasPrefix(p, prefix) {
+			files = append(files, p)
+			delete(w.files, p)
+		}
+	}
+	sort.Strings(files)
+	for _, p := range files {
+		events = append(events, filab.Event{
+			Type: filab.Delete,
+			Path: LocalPath(p),
+			Info: filab.FileInfo{Path: LocalPath(p)},
+		})
+	}
+	return events
+}
+
+func (w *inotify) run(ctx context.Context) {
+	defer close(w.ch)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+				w.send(ctx, filab.Event{Err: err})
+			}
+			return
+		}
+		var events []filab.Event
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(raw.Len)]
+			off += unix.SizeofInotifyEvent + int(raw.Len)
+			events = w.handle(events, int(raw.Wd), raw.Mask,
+				string(bytes.TrimRight(name, "\x00")))
+		}
+		for _, e := range events {
+			if !w.send(ctx, e) {
+				return
+			}
+		}
+	}
+}
+
+// handle appends events of a raw inotify event.
+func (w *inotify) handle(events []filab.Event, wd int, mask uint32, name string) []filab.Event {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return append(events, filab.Event{Err: errors.New("local: inotify queue overflow, events lost")})
+	}
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return events
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return events
+	}
+	if name == "" {
+		// Events of the watched directory itself.
+		return events
+	}
+	p := filepath.Join(dir, name)
+	if mask&unix.IN_ISDIR != 0 {
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			err := w.addTree(p, func(p string, fi os.FileInfo) {
+				events = append(events, filab.Event{
+					Type: filab.Create,
+					Path: LocalPath(p),
+					Info: fileInfo(LocalPath(p), fi),
+				})
+			})
+			if err != nil {
+				events = append(events, filab.Event{Path: LocalPath(p), Err: err})
+			}
+		} else if mask&unix.IN_MOVED_FROM != 0 {
+			events = w.removeTree(events, p)
+		}
+		return events
+	}
+	var t filab.EventType
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		t = filab.Create
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		t = filab.Modify
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		t = filab.Delete
+	default:
+		return events
+	}
+	e := filab.Event{Type: t, Path: LocalPath(p), Info: filab.FileInfo{Path: LocalPath(p)}}
+	if t == filab.Delete {
+		delete(w.files, p)
+	} else {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			// Removed in the meantime, a Delete event follows.
+			return events
+		}
+		e.Info = fileInfo(e.Path, fi)
+		w.files[p] = true
+	}
+	return append(events, e)
+}
+
+func (w *inotify) send(ctx context.Context, e filab.Event) bool {
+	select {
+	case w.ch <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package local
+
+import (
+	"context"
+
+	"github.com/datainq/filab"
+)
+
+// nativeWatch reports that Watch polls, there is no inotify.
+const nativeWatch = false
+
+func (d driver) Watch(ctx context.Context, p filab.Path, opts ...filab.WatchOption) (<-chan filab.Event, error) {
+	return filab.PollWatch(ctx, d, p, opts...)
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package local
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// nativeMetadata reports that metadata are stored in extended attributes.
+const nativeMetadata = true
+
+func getXattrs(file string) (map[string]string, error) {
+	names, err := listXattrs(file)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string)
+	for _, k := range names {
+		v, err := getXattr(file, k)
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// listXattrs returns names of extended attributes with xattrPrefix.
+func listXattrs(file string) ([]string, error) {
+	size, err := unix.Listxattr(file, nil)
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: file, Err: err}
+	}
+	buf := make([]byte, size)
+	size, err = unix.Listxattr(file, buf)
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: file, Err: err}
+	}
+	var ret []string
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if k := string(name); strings.HasPrefix(k, xattrPrefix) {
+			ret = append(ret, k)
+		}
+	}
+	return ret, nil
+}
+
+func getXattr(file, name string) (string, error) {
+	n, err := unix.Getxattr(file, name, nil)
+	if err != nil {
+		return "", &os.PathError{Op: "getxattr", Path: file, Err: err}
+	}
+	v := make([]byte, n)
+	n, err = unix.Getxattr(file, name, v)
+	if err != nil {
+		return "", &os.PathError{Op: "getxattr", Path: file, Err: err}
+	}
+	return string(v[:n]), nil
+}
+
+func setXattrs(file string, attrs map[string]string) error {
+	for k, v := range attrs {
+		if err := unix.Setxattr(file, k, []byte(v), 0); err != nil {
+			return &os.PathError{Op: "setxattr", Path: file, Err: err}
+		}
+	}
+	return nil
+}
+
+// removeXattrs removes extended attributes with xattrPrefix.
+func removeXattrs(file string) error {
+	names, err := listXattrs(file)
+	if err != nil {
+		return err
+	}
+	for _, k := range names {
+		if err := unix.Removexattr(file, k); err != nil && err != unix.ENODATA {
+			return &os.PathError{Op: "removexattr", Path: file, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package local
+
+import (
+	"errors"
+	"os"
+)
+
+// nativeMetadata reports that there are no extended attributes.
+const nativeMetadata = false
+
+func getXattrs(file string) (map[string]string, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: file, Err: errors.ErrUnsupported}
+}
+
+func getXattr(file, _ string) (string, error) {
+	return "", &os.PathError{Op: "getxattr", Path: file, Err: errors.ErrUnsupported}
+}
+
+func setXattrs(file string, _ map[string]string) error {
+	return &os.PathError{Op: "setxattr", Path: file, Err: errors.ErrUnsupported}
+}
+
+func removeXattrs(file string) error {
+	return &os.PathError{Op: "removexattr", Path: file, Err: errors.ErrUnsupported}
+}
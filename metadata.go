@@ -0,0 +1,67 @@
+package filab
+
+import (
+	"context"
+)
+
+// Metadata are attributes stored with a file.
+type Metadata struct {
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	// Custom are user defined key/values, e.g. a producer or a schema
+	// version.
+	Custom map[string]string
+}
+
+// MetadataStore is implemented by drivers with Capabilities.Metadata.
+type MetadataStore interface {
+	GetMetadata(ctx context.Context, p Path) (Metadata, error)
+	// SetMetadata updates non-empty fields of m and merges custom
+	// key/values into the stored ones.
+	SetMetadata(ctx context.Context, p Path, m Metadata) error
+}
+
+func (f *fileStore) metadataStore(op string, p Path) (MetadataStore, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := d.(MetadataStore)
+	if !ok || !d.Capabilities().Metadata {
+		return nil, &Error{Op: op, Path: p, Kind: ErrUnsupported}
+	}
+	return m, nil
+}
+
+func (f *fileStore) GetMetadata(ctx context.Context, p Path) (Metadata, error) {
+	m, err := f.metadataStore("getmetadata", p)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return m.GetMetadata(ctx, p)
+}
+
+func (f *fileStore) SetMetadata(ctx context.Context, p Path, md Metadata) error {
+	m, err := f.metadataStore("setmetadata", p)
+	if err != nil {
+		return err
+	}
+	return m.SetMetadata(ctx, p, md)
+}
+
+// WriteMetadata returns metadata set by write options.
+func (o WriteOptions) WriteMetadata() Metadata {
+	return Metadata{
+		ContentType:     o.ContentType,
+		ContentEncoding: o.ContentEncoding,
+		CacheControl:    o.CacheControl,
+		Custom:          o.Metadata,
+	}
+}
+
+// IsZero reports whether m has no attributes.
+func (m Metadata) IsZero() bool {
+	return m.ContentType == "" && m.ContentEncoding == "" && m.CacheControl == "" &&
+		len(m.Custom) == 0
+}
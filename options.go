@@ -0,0 +1,288 @@
+package filab
+
+import (
+	"os"
+	"time"
+)
+
+// NoCompression passed to WithCompression disables compression
+// detected from a file suffix.
+const NoCompression = "none"
+
+// ReadOptions are per call options of a read. Drivers ignore options
+// they cannot interpret.
+type ReadOptions struct {
+	// Compression is a name of a codec used instead of the one detected
+	// from a file suffix by the S-methods, DetectCompression sniffs
+	// the content.
+	Compression string
+	// VerifyChecksum makes Close of a reader fail with ErrChecksum if
+	// the read content does not match checksums of the file.
+	VerifyChecksum bool
+	// ReadAhead is a minimal size of a range request of a seekable reader,
+	// 0 means DefaultReadAhead.
+	ReadAhead int
+}
+
+type ReadOption interface {
+	applyRead(*ReadOptions)
+}
+
+// NewReadOptions applies opts on top of the default options.
+func NewReadOptions(opts ...ReadOption) ReadOptions {
+	var o ReadOptions
+	for _, v := range opts {
+		v.applyRead(&o)
+	}
+	return o
+}
+
+// WriteOptions are per call options of a write. Drivers ignore options
+// they cannot interpret.
+type WriteOptions struct {
+	ContentType string
+	// ContentEncoding is stored with a file, it does not compress it.
+	ContentEncoding string
+	CacheControl    string
+	// Metadata are custom key/values stored with a file.
+	Metadata map[string]string
+	// Compression is a name of a codec used instead of the one detected
+	// from a file suffix by the S-methods.
+	Compression string
+	// CompressionLevel overrides a level of a codec if CompressionLevelSet,
+	// otherwise the level set for a FileStorage or the codec is used.
+	CompressionLevel    int
+	CompressionLevelSet bool
+	// Parallelism is a number of goroutines compressing, codecs without
+	// a parallel writer ignore it.
+	Parallelism int
+	// CRC32C of the whole content, if it is known before writing, lets
+	// a driver verify the upload. See WithCRC32C.
+	CRC32C     uint32
+	SendCRC32C bool
+	// ChunkSize is a size of a buffer of an upload, 0 means a driver default.
+	ChunkSize int
+	// FileMode is a mode of a created file, 0 means a driver default.
+	FileMode os.FileMode
+
+	// Preconditions, a write fails with ErrPrecondition if any is not met.
+	IfNotExist        bool
+	IfGenerationMatch int64
+	IfModTimeMatch    time.Time
+}
+
+type WriteOption interface {
+	applyWrite(*WriteOptions)
+}
+
+// NewWriteOptions applies opts on top of the default options.
+func NewWriteOptions(opts ...WriteOption) WriteOptions {
+	var o WriteOptions
+	for _, v := range opts {
+		v.applyWrite(&o)
+	}
+	return o
+}
+
+// ReadWriteOption can be used both for reads and writes.
+type ReadWriteOption interface {
+	ReadOption
+	WriteOption
+}
+
+type withCompression string
+
+func (c withCompression) applyRead(o *ReadOptions) {
+	o.Compression = string(c)
+}
+
+func (c withCompression) applyWrite(o *WriteOptions) {
+	o.Compression = string(c)
+}
+
+// WithCompression forces a codec instead of detecting it by a suffix,
+// use NoCompression to read or write raw bytes and DetectCompression
+// to detect a codec of a read from the content.
+func WithCompression(name string) ReadWriteOption {
+	return withCompression(name)
+}
+
+type withCompressionLevel int
+
+func (l withCompressionLevel) applyWrite(o *WriteOptions) {
+	o.CompressionLevel = int(l)
+	o.CompressionLevelSet = true
+}
+
+// WithCompressionLevel sets a level of a codec used by the S-methods,
+// e.g. gzip.BestSpeed.
+func WithCompressionLevel(level int) WriteOption {
+	return withCompressionLevel(level)
+}
+
+type withParallelism int
+
+func (n withParallelism) applyWrite(o *WriteOptions) {
+	o.Parallelism = int(n)
+}
+
+// WithParallelism compresses with n goroutines. The output of a parallel
+// gzip writer is a standard gzip stream.
+func WithParallelism(n int) WriteOption {
+	return withParallelism(n)
+}
+
+type verifyChecksum struct{}
+
+func (verifyChecksum) applyRead(o *ReadOptions) {
+	o.VerifyChecksum = true
+}
+
+// VerifyChecksum verifies a content read to the end with checksums
+// reported by Stat, Close of the reader fails with ErrChecksum on
+// a mismatch. Opening fails with ErrUnsupported if the checksums are not
+// known.
+func VerifyChecksum() ReadOption {
+	return verifyChecksum{}
+}
+
+type withReadAhead int
+
+func (n withReadAhead) applyRead(o *ReadOptions) {
+	o.ReadAhead = int(n)
+}
+
+// WithReadAhead sets a minimal size of a range request of a seekable
+// reader of an object store.
+func WithReadAhead(n int) ReadOption {
+	return withReadAhead(n)
+}
+
+type withCRC32C uint32
+
+func (c withCRC32C) applyWrite(o *WriteOptions) {
+	o.CRC32C = uint32(c)
+	o.SendCRC32C = true
+}
+
+// WithCRC32C passes a known CRC32C of the whole written content, a driver
+// rejects the write if the content does not match.
+func WithCRC32C(c uint32) WriteOption {
+	return withCRC32C(c)
+}
+
+type withContentType string
+
+func (c withContentType) applyWrite(o *WriteOptions) {
+	o.ContentType = string(c)
+}
+
+func WithContentType(t string) WriteOption {
+	return withContentType(t)
+}
+
+type withContentEncoding string
+
+func (e withContentEncoding) applyWrite(o *WriteOptions) {
+	o.ContentEncoding = string(e)
+}
+
+// WithContentEncoding stores an encoding of the written content, e.g. gzip
+// for content compressed with WithCompression.
+func WithContentEncoding(e string) WriteOption {
+	return withContentEncoding(e)
+}
+
+type withCacheControl string
+
+func (c withCacheControl) applyWrite(o *WriteOptions) {
+	o.CacheControl = string(c)
+}
+
+func WithCacheControl(c string) WriteOption {
+	return withCacheControl(c)
+}
+
+type withMetadata map[string]string
+
+func (m withMetadata) applyWrite(o *WriteOptions) {
+	if o.Metadata == nil {
+		o.Metadata = make(map[string]string, len(m))
+	}
+	for k, v := range m {
+		o.Metadata[k] = v
+	}
+}
+
+// WithMetadata adds custom key/values stored with a file.
+func WithMetadata(m map[string]string) WriteOption {
+	return withMetadata(m)
+}
+
+type withChunkSize int
+
+func (c withChunkSize) applyWrite(o *WriteOptions) {
+	o.ChunkSize = int(c)
+}
+
+func WithChunkSize(n int) WriteOption {
+	return withChunkSize(n)
+}
+
+type withFileMode os.FileMode
+
+func (m withFileMode) applyWrite(o *WriteOptions) {
+	o.FileMode = os.FileMode(m)
+}
+
+func WithFileMode(mode os.FileMode) WriteOption {
+	return withFileMode(mode)
+}
+
+type ifNotExist struct{}
+
+func (ifNotExist) applyWrite(o *WriteOptions) {
+	o.IfNotExist = true
+}
+
+// IfNotExist makes a write fail if the file already exists.
+func IfNotExist() WriteOption {
+	return ifNotExist{}
+}
+
+type ifGenerationMatch int64
+
+func (g ifGenerationMatch) applyWrite(o *WriteOptions) {
+	o.IfGenerationMatch = int64(g)
+}
+
+// IfGenerationMatch makes a write fail unless the file exists and its
+// FileInfo.Generation is gen.
+func IfGenerationMatch(gen int64) WriteOption {
+	return ifGenerationMatch(gen)
+}
+
+type ifModTimeMatch time.Time
+
+func (t ifModTimeMatch) applyWrite(o *WriteOptions) {
+	o.IfModTimeMatch = time.Time(t)
+}
+
+// IfModTimeMatch makes a write fail unless the file exists and its
+// FileInfo.ModTime is t.
+func IfModTimeMatch(t time.Time) WriteOption {
+	return ifModTimeMatch(t)
+}
+
+// CheckPreconditions verifies generation and modification time
+// preconditions against info of an existing file. It is meant for drivers
+// which cannot check them natively.
+func (o WriteOptions) CheckPreconditions(info FileInfo) error {
+	if o.IfGenerationMatch != 0 && o.IfGenerationMatch != info.Generation {
+		return ErrPrecondition
+	}
+	if !o.IfModTimeMatch.IsZero() && !o.IfModTimeMatch.Equal(info.ModTime) {
+		return ErrPrecondition
+	}
+	return nil
+}
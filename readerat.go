@@ -0,0 +1,34 @@
+package filab
+
+import (
+	"context"
+	"io"
+)
+
+type readerAt struct {
+	ctx context.Context
+	s   FileStoreBase
+	p   Path
+}
+
+// NewReaderAt returns an io.ReaderAt which serves each ReadAt call with
+// a range read of p.
+func NewReaderAt(ctx context.Context, s FileStoreBase, p Path) io.ReaderAt {
+	return readerAt{ctx, s, p}
+}
+
+func (r readerAt) ReadAt(b []byte, off int64) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	rc, err := r.s.NewRangeReader(r.ctx, r.p, off, int64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	n, err := io.ReadFull(rc, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,216 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type schemeDriver struct {
+	prefix string
+	driver StorageDriver
+}
+
+func (f *fileStore) RegisterDriver(driver StorageDriver) error {
+	return f.register(driver, func(d StorageDriver) bool {
+		return f.defaults[d.Name()]
+	})
+}
+
+func (f *fileStore) ReplaceDriver(driver StorageDriver) error {
+	return f.register(driver, func(d StorageDriver) bool {
+		return d.Name() == driver.Name()
+	})
+}
+
+// registerDefault adds a driver which a later registration of a driver with
+// the same name, type or scheme replaces.
+func (f *fileStore) registerDefault(driver StorageDriver) error {
+	if err := f.register(driver, nil); err != nil {
+		return err
+	}
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.defaults[driver.Name()] = true
+	return nil
+}
+
+// register adds a driver, registered drivers with the same name, type or
+// scheme are removed and closed if they are replaceable, otherwise it fails.
+func (f *fileStore) register(driver StorageDriver, replaceable func(StorageDriver) bool) error {
+	f.m.Lock()
+	replaced, err := f.collisions(driver, replaceable)
+	if err != nil {
+		f.m.Unlock()
+		return err
+	}
+	for _, d := range replaced {
+		f.remove(d)
+	}
+	f.add(driver)
+	f.m.Unlock()
+
+	var errs []error
+	for _, d := range replaced {
+		if err := d.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("filab: close replaced %q: %w", d.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collisions returns registered drivers with the same name, type or scheme
+// as driver. It fails on the first one which is not replaceable.
+func (f *fileStore) collisions(driver StorageDriver, replaceable func(StorageDriver) bool) ([]StorageDriver, error) {
+	var ret []StorageDriver
+	collide := func(d StorageDriver, err error) error {
+		if replaceable == nil || !replaceable(d) {
+			return err
+		}
+		for _, v := range ret {
+			if v.Name() == d.Name() {
+				return nil
+			}
+		}
+		ret = append(ret, d)
+		return nil
+	}
+	name := driver.Name()
+	if d, ok := f.byName[name]; ok {
+		if err := collide(d, fmt.Errorf("filab: driver %q: %w", name, ErrExist)); err != nil {
+			return nil, err
+		}
+	}
+	if d, ok := f.byType[driver.Type()]; ok {
+		if err := collide(d, fmt.Errorf("filab: driver type of %q: %w", name, ErrExist)); err != nil {
+			return nil, err
+		}
+	}
+	if scheme := driver.Scheme(); scheme == "" {
+		if f.local != nil {
+			err := fmt.Errorf("filab: driver without scheme %q: %w", name, ErrExist)
+			if err := collide(f.local, err); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for _, v := range f.schemes {
+			if v.prefix == scheme+"://" {
+				err := fmt.Errorf("filab: scheme %q of %q: %w", scheme, name, ErrExist)
+				if err := collide(v.driver, err); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (f *fileStore) add(driver StorageDriver) {
+	if scheme := driver.Scheme(); scheme == "" {
+		f.local = driver
+	} else {
+		f.schemes = append(f.schemes, schemeDriver{scheme + "://", driver})
+		sort.SliceStable(f.schemes, func(i, j int) bool {
+			return len(f.schemes[i].prefix) > len(f.schemes[j].prefix)
+		})
+	}
+	f.drivers = append(f.drivers, driver)
+	f.byName[driver.Name()] = driver
+	f.byType[driver.Type()] = driver
+}
+
+func (f *fileStore) remove(driver StorageDriver) {
+	if f.local == driver {
+		f.local = nil
+	}
+	for i, v := range f.schemes {
+		if v.driver == driver {
+			f.schemes = append(f.schemes[:i], f.schemes[i+1:]...)
+			break
+		}
+	}
+	for i, v := range f.drivers {
+		if v == driver {
+			f.drivers = append(f.drivers[:i], f.drivers[i+1:]...)
+			break
+		}
+	}
+	delete(f.byName, driver.Name())
+	delete(f.byType, driver.Type())
+	delete(f.defaults, driver.Name())
+}
+
+func (f *fileStore) Driver(name string) (StorageDriver, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	d, ok := f.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("filab: driver %q: %w", name, ErrNoDriver)
+	}
+	return d, nil
+}
+
+func (f *fileStore) Drivers() []string {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	ret := make([]string, 0, len(f.byName))
+	for k := range f.byName {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (f *fileStore) Init(ctx context.Context) error {
+	f.m.RLock()
+	drivers := append([]StorageDriver(nil), f.drivers...)
+	f.m.RUnlock()
+	for _, d := range drivers {
+		if err := d.Init(ctx); err != nil {
+			return fmt.Errorf("filab: init %q: %w", d.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (f *fileStore) Close() error {
+	f.m.RLock()
+	drivers := append([]StorageDriver(nil), f.drivers...)
+	f.m.RUnlock()
+	var errs []error
+	for i := len(drivers) - 1; i >= 0; i-- {
+		if err := drivers[i].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("filab: close %q: %w", drivers[i].Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// driver returns a driver handling a path.
+func (f *fileStore) driver(p Path) (StorageDriver, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	d, ok := f.byType[p.Type()]
+	if !ok {
+		return nil, &Error{Op: "dispatch", Path: p, Kind: ErrNoDriver}
+	}
+	return d, nil
+}
+
+// driverForString returns a driver able to parse s.
+func (f *fileStore) driverForString(s string) (StorageDriver, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+	for _, v := range f.schemes {
+		if strings.HasPrefix(s, v.prefix) {
+			return v.driver, nil
+		}
+	}
+	if f.local == nil {
+		return nil, fmt.Errorf("filab: parse %q: %w", s, ErrNoDriver)
+	}
+	return f.local, nil
+}
@@ -0,0 +1,134 @@
+package filab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultReadAhead is a minimal size of a range request of a seekable
+// reader of an object store.
+const DefaultReadAhead = 1 << 20
+
+// SeekableOpener is implemented by drivers with native seekable readers.
+type SeekableOpener interface {
+	OpenSeekable(ctx context.Context, p Path, opts ...ReadOption) (io.ReadSeekCloser, error)
+}
+
+func (f *fileStore) OpenSeekable(ctx context.Context, p Path, opts ...ReadOption) (io.ReadSeekCloser, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := d.(SeekableOpener); ok {
+		return s.OpenSeekable(ctx, p, opts...)
+	}
+	return NewSeekableReader(ctx, f, p, opts...)
+}
+
+// seekableReader serves reads from a buffer filled by range reads of at
+// least a read-ahead size, so sequential reads do not make a request each.
+type seekableReader struct {
+	ctx       context.Context
+	s         FileStoreBase
+	p         Path
+	opts      []ReadOption
+	size      int64
+	readAhead int
+
+	off    int64
+	buf    []byte
+	bufOff int64
+	closed bool
+}
+
+// NewSeekableReader returns a reader of p which seeks with range reads of
+// a store. The size of p is taken from Stat when it is opened.
+func NewSeekableReader(ctx context.Context, s FileStoreBase, p Path, opts ...ReadOption) (io.ReadSeekCloser, error) {
+	info, err := s.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	readAhead := NewReadOptions(opts...).ReadAhead
+	if readAhead <= 0 {
+		readAhead = DefaultReadAhead
+	}
+	return &seekableReader{
+		ctx:       ctx,
+		s:         s,
+		p:         p,
+		opts:      opts,
+		size:      info.Size,
+		readAhead: readAhead,
+	}, nil
+}
+
+func (r *seekableReader) Read(b []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if r.off < r.bufOff || r.off >= r.bufOff+int64(len(r.buf)) {
+		if err := r.fill(len(b)); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, r.buf[r.off-r.bufOff:])
+	r.off += int64(n)
+	return n, nil
+}
+
+// fill reads a range starting at the offset, at least n bytes long.
+func (r *seekableReader) fill(n int) error {
+	if n < r.readAhead {
+		n = r.readAhead
+	}
+	if left := r.size - r.off; int64(n) > left {
+		n = int(left)
+	}
+	rc, err := r.s.NewRangeReader(r.ctx, r.p, r.off, int64(n), r.opts...)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+	r.buf = r.buf[:n]
+	m, err := io.ReadFull(rc, r.buf)
+	r.buf, r.bufOff = r.buf[:m], r.off
+	if err == io.ErrUnexpectedEOF && m > 0 {
+		// The file was truncated since it was opened.
+		r.size = r.off + int64(m)
+		err = nil
+	}
+	return err
+}
+
+func (r *seekableReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("filab: seek %s: invalid whence %d", r.p, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("filab: seek %s: negative position %d", r.p, offset)
+	}
+	r.off = offset
+	return offset, nil
+}
+
+func (r *seekableReader) Close() error {
+	r.buf, r.closed = nil, true
+	return nil
+}
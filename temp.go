@@ -0,0 +1,141 @@
+package filab
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Temp is a temporary file or directory. It is removed by Remove or when
+// the context it was created with is done.
+type Temp struct {
+	Path Path
+
+	once   sync.Once
+	remove func(context.Context) error
+	stop   func() bool
+	err    error
+}
+
+// Remove removes the temporary file or directory with its content, it is
+// safe to call it many times.
+func (t *Temp) Remove() error {
+	t.stop()
+	t.once.Do(func() {
+		t.err = t.remove(context.Background())
+	})
+	return t.err
+}
+
+func newTemp(ctx context.Context, p Path, remove func(context.Context) error) *Temp {
+	t := &Temp{Path: p, remove: remove}
+	t.stop = context.AfterFunc(ctx, func() {
+		t.once.Do(func() {
+			t.err = t.remove(context.Background())
+		})
+	})
+	return t
+}
+
+// TempWriter writes a temporary file. Close makes the content visible,
+// the file exists until Remove.
+type TempWriter struct {
+	AtomicWriter
+	*Temp
+}
+
+// lockedWriter serializes calls of an atomic writer, the removal of a temp
+// file aborts it on another goroutine when a context is done.
+type lockedWriter struct {
+	m    sync.Mutex
+	w    AtomicWriter
+	done bool
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.done {
+		return 0, os.ErrClosed
+	}
+	return l.w.Write(b)
+}
+
+func (l *lockedWriter) Commit() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.done {
+		return os.ErrClosed
+	}
+	l.done = true
+	return l.w.Commit()
+}
+
+func (l *lockedWriter) Close() error {
+	return l.Commit()
+}
+
+func (l *lockedWriter) Abort() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.done {
+		return nil
+	}
+	l.done = true
+	return l.w.Abort()
+}
+
+// tempName replaces the last * of a pattern with a random string or
+// appends it.
+func tempName(pattern string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	r := hex.EncodeToString(b)
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return pattern[:i] + r + pattern[i+1:], nil
+	}
+	return pattern + r, nil
+}
+
+func (f *fileStore) TempFile(ctx context.Context, dir Path, pattern string, opts ...WriteOption) (*TempWriter, error) {
+	name, err := tempName(pattern)
+	if err != nil {
+		return nil, err
+	}
+	p := dir.Join(name)
+	opts = append(append([]WriteOption(nil), opts...), IfNotExist())
+	aw, err := f.NewAtomicWriter(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	w := &lockedWriter{w: aw}
+	t := newTemp(ctx, p, func(ctx context.Context) error {
+		w.Abort()
+		err := f.Delete(ctx, p)
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+	return &TempWriter{w, t}, nil
+}
+
+func (f *fileStore) TempDir(ctx context.Context, dir Path, pattern string) (*Temp, error) {
+	name, err := tempName(pattern)
+	if err != nil {
+		return nil, err
+	}
+	p := dir.Join(name)
+	if err := f.MkdirAll(ctx, p); err != nil {
+		return nil, err
+	}
+	return newTemp(ctx, p, func(ctx context.Context) error {
+		return f.RemoveAll(ctx, p)
+	}), nil
+}
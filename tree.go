@@ -0,0 +1,109 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultConcurrency is a number of parallel requests of operations on
+// many files.
+const DefaultConcurrency = 16
+
+// TreeRemover is implemented by drivers which can remove a directory with
+// its content natively.
+type TreeRemover interface {
+	RemoveAll(ctx context.Context, p Path) error
+}
+
+// DirMaker is implemented by drivers with real directories. Other drivers
+// have implicit directories, MkdirAll is a no-op for them.
+type DirMaker interface {
+	MkdirAll(ctx context.Context, p Path) error
+}
+
+func (f *fileStore) RemoveAll(ctx context.Context, p Path) error {
+	d, err := f.driver(p)
+	if err != nil {
+		return err
+	}
+	if r, ok := d.(TreeRemover); ok {
+		return r.RemoveAll(ctx, p)
+	}
+	return RemoveTree(ctx, d, p)
+}
+
+func (f *fileStore) MkdirAll(ctx context.Context, p Path) error {
+	d, err := f.driver(p)
+	if err != nil {
+		return err
+	}
+	if m, ok := d.(DirMaker); ok {
+		return m.MkdirAll(ctx, p)
+	}
+	return nil
+}
+
+// RemoveTree removes p and all files below it found by Walk with
+// DefaultConcurrency deletes in parallel. Files which fail to be removed
+// are reported together, a missing p is not an error.
+func RemoveTree(ctx context.Context, s FileStoreBase, p Path) error {
+	var files []Path
+	err := s.Walk(ctx, p, func(p Path, info FileInfo, err error) error {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return DeleteFiles(ctx, s, files)
+}
+
+// DeleteFiles deletes files with DefaultConcurrency deletes in parallel.
+// Already missing files are not an error, other failures are joined.
+func DeleteFiles(ctx context.Context, s FileStoreBase, files []Path) error {
+	var errs []error
+	for _, v := range BatchDelete(ctx, s, files) {
+		if v.Err != nil && !errors.Is(v.Err, ErrNotExist) {
+			errs = append(errs, v.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// forEach calls fn for indexes in [0, n) from at most workers goroutines.
+// It stops starting calls when ctx is done.
+func forEach(ctx context.Context, n, workers int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for k := 0; k < workers; k++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	defer func() {
+		close(next)
+		wg.Wait()
+	}()
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package filab
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// SkipDir returned by a WalkFunc skips the directory the function was
+// called for. Returned for a file it skips the remaining files in
+// the containing directory.
+var SkipDir = filepath.SkipDir
+
+// SkipAll returned by a WalkFunc stops the walk without an error.
+var SkipAll = filepath.SkipAll
+
+// WalkFunc is called by Walk for each file and directory, including
+// the root. If err is not nil, the path could not be read (for a directory
+// it may be called a second time with an error from reading its entries)
+// and info may be incomplete. The WalkFunc may return SkipDir or SkipAll.
+type WalkFunc func(p Path, info FileInfo, err error) error
+
+// WalkTree walks a file tree using Stat and ReadDir of a store. It gives
+// the same semantics as filepath.Walk to stores without a native walk,
+// entries in each directory are visited in the order of names.
+func WalkTree(ctx context.Context, s FileStoreBase, root Path, fn WalkFunc) error {
+	info, err := s.Stat(ctx, root)
+	if err != nil {
+		entries, dirErr := s.ReadDir(ctx, root)
+		if dirErr == nil && len(entries) > 0 {
+			info, err = FileInfo{Path: root, IsDir: true}, nil
+		}
+	}
+	if err != nil {
+		err = fn(root, FileInfo{Path: root}, err)
+	} else {
+		err = walkTree(ctx, s, info, fn)
+	}
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkTree(ctx context.Context, s FileStoreBase, info FileInfo, fn WalkFunc) error {
+	if !info.IsDir {
+		return fn(info.Path, info, nil)
+	}
+	if err := fn(info.Path, info, nil); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := s.ReadDir(ctx, info.Path)
+	if err != nil {
+		if err = fn(info.Path, info, err); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := walkTree(ctx, s, e, fn); err != nil {
+			if !e.IsDir || err != SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package filab
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is an interval of listings of a polling watch.
+const DefaultPollInterval = 10 * time.Second
+
+// EventType is a kind of a change of a file.
+type EventType int
+
+const (
+	Create EventType = iota + 1
+	Modify
+	Delete
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Create:
+		return "create"
+	case Modify:
+		return "modify"
+	case Delete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Event describes a change of a file below a watched path. Events of
+// directories are not reported.
+type Event struct {
+	Type EventType
+	Path Path
+	// Info of a created or modified file, only Path is set for Delete.
+	Info FileInfo
+	// Err is set when changes could not be observed, e.g. a listing
+	// failed or a kernel queue overflowed. The watch continues.
+	Err error
+}
+
+// Watcher is implemented by drivers with native change notifications.
+// Watch emits events of files below p until ctx is done, then it closes
+// the channel. It fails with ErrUnsupported for paths it cannot watch,
+// FileStorage polls them.
+type Watcher interface {
+	Watch(ctx context.Context, p Path, opts ...WatchOption) (<-chan Event, error)
+}
+
+// WatchOptions configure a watch.
+type WatchOptions struct {
+	// PollInterval is an interval of listings of a polling watch.
+	PollInterval time.Duration
+}
+
+type WatchOption interface {
+	applyWatch(*WatchOptions)
+}
+
+// NewWatchOptions applies opts on top of the default options.
+func NewWatchOptions(opts ...WatchOption) WatchOptions {
+	o := WatchOptions{PollInterval: DefaultPollInterval}
+	for _, v := range opts {
+		v.applyWatch(&o)
+	}
+	return o
+}
+
+type withPollInterval time.Duration
+
+func (d withPollInterval) applyWatch(o *WatchOptions) {
+	o.PollInterval = time.Duration(d)
+}
+
+// WithPollInterval sets an interval of listings of a polling watch,
+// native watches ignore it.
+func WithPollInterval(d time.Duration) WatchOption {
+	return withPollInterval(d)
+}
+
+func (f *fileStore) Watch(ctx context.Context, p Path, opts ...WatchOption) (<-chan Event, error) {
+	d, err := f.driver(p)
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := d.(Watcher); ok && d.Capabilities().Watch {
+		ch, err := w.Watch(ctx, p, opts...)
+		if !errors.Is(err, ErrUnsupported) {
+			return ch, err
+		}
+		// E.g. a file which native notifications cannot watch.
+	}
+	return PollWatch(ctx, d, p, opts...)
+}
+
+// PollWatch watches p by diffing listings of files of a store. A file is
+// modified if its generation changes or, without generations, its size
+// or modification time. Changes made before the call are not reported.
+func PollWatch(ctx context.Context, s FileStoreBase, p Path, opts ...WatchOption) (<-chan Event, error) {
+	o := NewWatchOptions(opts...)
+	files, err := pollFiles(ctx, s, p)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(o.PollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			current, err := pollFiles(ctx, s, p)
+			var events []Event
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				events = []Event{{Path: p, Err: err}}
+			} else {
+				events = diffFiles(files, current)
+				files = current
+			}
+			for _, e := range events {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// pollFiles lists files below p by a path, a missing p has no files.
+// Drivers with real directories are walked, object stores are listed.
+func pollFiles(ctx context.Context, s FileStoreBase, p Path) (map[string]FileInfo, error) {
+	if _, ok := s.(DirMaker); !ok && strings.Contains(p.String(), "://") && !strings.HasSuffix(p.String(), "://") {
+		return listFiles(ctx, s, p)
+	}
+	ret := make(map[string]FileInfo)
+	err := s.Walk(ctx, p, func(p Path, info FileInfo, err error) error {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir {
+			ret[p.String()] = info
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// listFiles lists objects below p by one flat listing. Infos come with
+// the listing if its iterator is an InfoIterator. A p without objects
+// below it may be an object itself.
+func listFiles(ctx context.Context, s FileStoreBase, p Path) (map[string]FileInfo, error) {
+	ret := make(map[string]FileInfo)
+	it, err := s.ListIter(ctx, p, ListOptions{})
+	if errors.Is(err, ErrNotExist) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	infos, _ := it.(InfoIterator)
+	for {
+		f, err := it.Next()
+		if err == Done || errors.Is(err, ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(f.String(), "/") {
+			// A directory placeholder.
+			continue
+		}
+		var info FileInfo
+		if infos != nil {
+			info = infos.Info()
+		} else if info, err = s.Stat(ctx, f); errors.Is(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ret[f.String()] = info
+	}
+	if len(ret) == 0 {
+		info, err := s.Stat(ctx, p)
+		if err == nil && !info.IsDir {
+			ret[p.String()] = info
+		} else if err != nil && !errors.Is(err, ErrNotExist) {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// diffFiles returns events sorted by a path.
+func diffFiles(prev, current map[string]FileInfo) []Event {
+	var events []Event
+	for k, v := range current {
+		old, ok := prev[k]
+		if !ok {
+			events = append(events, Event{Type: Create, Path: v.Path, Info: v})
+		} else if changed(old, v) {
+			events = append(events, Event{Type: Modify, Path: v.Path, Info: v})
+		}
+	}
+	for k, v := range prev {
+		if _, ok := current[k]; !ok {
+			events = append(events, Event{Type: Delete, Path: v.Path, Info: FileInfo{Path: v.Path}})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path.String() < events[j].Path.String()
+	})
+	return events
+}
+
+func changed(a, b FileInfo) bool {
+	if a.Generation != 0 || b.Generation != 0 {
+		return a.Generation != b.Generation
+	}
+	return a.Size != b.Size || !a.ModTime.Equal(b.ModTime)
+}